@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	. "github.com/usace/microauth"
+)
+
+// APIKeyHeader carries a service account API key in place of a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// KeyAuth accepts service account API keys alongside the tokens verified by Next.  Only the routes wrapped
+// with AuthorizeKeyRoute accept keys, and only for keys carrying the route's permission on the survey in
+// the url.  Requests without a key are authorized by Next.
+type KeyAuth struct {
+	Next  RouteAuthorizer
+	Store *stores.SurveyStore
+}
+
+func (a *KeyAuth) AuthorizeRoute(handler echo.HandlerFunc, roles ...int) echo.HandlerFunc {
+	return a.Next.AuthorizeRoute(handler, roles...)
+}
+
+// AuthorizeKeyRoute authorizes a route for tokens holding one of the roles or API keys holding the permission.
+// Requests made with a key run as the key's service account.
+func (a *KeyAuth) AuthorizeKeyRoute(permission string, handler echo.HandlerFunc, roles ...int) echo.HandlerFunc {
+	next := a.Next.AuthorizeRoute(handler, roles...)
+	return func(c echo.Context) error {
+		presented := c.Request().Header.Get(APIKeyHeader)
+		if presented == "" {
+			return next(c)
+		}
+		key, err := a.Store.AuthenticateAPIKey(presented)
+		if err != nil {
+			if err != stores.ErrInvalidAPIKey {
+				log.Printf("Error authenticating API key: %s", err)
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		}
+		surveyId, err := uuid.Parse(c.Param("surveyid"))
+		if err != nil || !key.Permits(permission, surveyId) {
+			return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
+		}
+		c.Set("NSIUSER", JwtClaim{
+			Sub:      models.ServiceSubject(key.AccountID),
+			UserName: key.Prefix,
+			Roles:    []string{},
+		})
+		c.Set("NSISURVEY", surveyId)
+		return handler(c)
+	}
+}
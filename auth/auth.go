@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"log"
+
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
 	"github.com/google/uuid"
@@ -13,15 +15,51 @@ const (
 	ADMIN
 	SURVEY_OWNER
 	SURVEY_MEMBER
+	SURVEY_COORDINATOR
+	SURVEY_REVIEWER
+	SURVEY_SURVEYOR
+	SURVEY_VIEWER
 )
 
+// rolePermissions is the permission matrix mapping each survey member role to the route roles it satisfies.
+// SURVEY_MEMBER is satisfied by any membership.
+var rolePermissions = map[string][]int{
+	models.RoleOwner:       {SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_SURVEYOR, SURVEY_VIEWER, SURVEY_MEMBER},
+	models.RoleCoordinator: {SURVEY_COORDINATOR, SURVEY_VIEWER, SURVEY_MEMBER},
+	models.RoleReviewer:    {SURVEY_REVIEWER, SURVEY_VIEWER, SURVEY_MEMBER},
+	models.RoleSurveyor:    {SURVEY_SURVEYOR, SURVEY_MEMBER},
+	models.RoleViewer:      {SURVEY_VIEWER, SURVEY_MEMBER},
+}
+
+// RolePermits reports whether a survey member role satisfies any of the route roles
+func RolePermits(role string, roles []int) bool {
+	for _, r := range rolePermissions[role] {
+		if Contains(roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
 func Appauth(c echo.Context, authstore interface{}, roles []int, claims JwtClaim) bool {
 	c.Set("NSIUSER", claims)
 	store := authstore.(*stores.SurveyStore)
-	store.AddUser(models.User{
+	fresh, err := store.RefreshUser(models.UserProfile{
 		UserID:   claims.Sub,
 		Username: claims.UserName,
-	})
+		Email:    claims.Email,
+	}, claims.Roles)
+	if err != nil {
+		log.Printf("Error refreshing user %s: %s", claims.Sub, err)
+	}
+	if fresh {
+		if err := store.AcceptInvitations(claims.Sub, claims.Email); err != nil {
+			log.Printf("Error accepting invitations for %s: %s", claims.Sub, err)
+		}
+		if err := store.SyncGroupMemberships(claims.Sub, claims.Roles); err != nil {
+			log.Printf("Error syncing group memberships for %s: %s", claims.Sub, err)
+		}
+	}
 
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if c.Param("surveyid") != "" && err == nil { // there is surveyId in url
@@ -32,14 +70,9 @@ func Appauth(c echo.Context, authstore interface{}, roles []int, claims JwtClaim
 		if Contains(roles, ADMIN) && Contains_string(claims.Roles, "ADMIN") {
 			return true
 		}
-		var flagOwner, flagMember bool
-		if Contains(roles, SURVEY_OWNER) {
-			flagOwner = store.IsOwner(surveyId, claims.Sub)
-		}
-		if Contains(roles, SURVEY_MEMBER) {
-			flagMember = store.IsMember(surveyId, claims.Sub)
-		}
-		if flagMember || flagOwner {
+		role := store.GetMemberRole(surveyId, claims.Sub)
+		c.Set("NSIROLE", role)
+		if RolePermits(role, roles) {
 			return true
 		}
 	}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRolePermits(t *testing.T) {
+	// the roles of the new structure review route
+	review := []int{ADMIN, SURVEY_OWNER, SURVEY_REVIEWER}
+	tests := []struct {
+		role    string
+		permits bool
+	}{
+		{models.RoleOwner, true},
+		{models.RoleReviewer, true},
+		{models.RoleCoordinator, false},
+		{models.RoleSurveyor, false},
+		{models.RoleViewer, false},
+		{"", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.permits, RolePermits(test.role, review), test.role)
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	. "github.com/usace/microauth"
+)
+
+const devIssuer = "nsi-survey-dev"
+
+// DevAuth issues tokens for local development.  It generates an RSA key pair at startup and loads the public
+// key into the microauth verifier in place of the identity provider key, so the tokens it mints pass through
+// AuthorizeRoute and Appauth exactly as identity provider tokens do.  It must never be enabled in a deployed
+// environment.
+type DevAuth struct {
+	key *rsa.PrivateKey
+	aud string
+	ttl time.Duration
+}
+
+type DevTokenRequest struct {
+	Sub       string   `json:"sub"`
+	UserName  string   `json:"userName"`
+	Email     string   `json:"email"`
+	Roles     []string `json:"roles"`
+	ExpiresIn int      `json:"expiresIn"`
+}
+
+type devToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// NewDevAuth generates a key pair and loads its public key into auth.  Tokens expire after ttl unless the
+// request asks for a different lifetime.
+func NewDevAuth(auth *Auth, ttl time.Duration) (*DevAuth, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile("", "nsi-survey-dev-*.pem")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	err = pem.Encode(f, &pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = auth.LoadVerificationKey(VerificationKeyOptions{
+		KeySource: KeyFile,
+		KeyVal:    f.Name(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DevAuth{key: key, aud: auth.Aud, ttl: ttl}, nil
+}
+
+// Mint signs a token carrying the requested subject, name, email, and roles
+func (d *DevAuth) Mint(req DevTokenRequest) (string, time.Time, error) {
+	ttl := d.ttl
+	if req.ExpiresIn > 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+	roles := req.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	claims := jwt.MapClaims{
+		"iss":                devIssuer,
+		"sub":                req.Sub,
+		"aud":                []string{d.aud},
+		"roles":              roles,
+		"preferred_username": req.UserName,
+		"email":              req.Email,
+		"iat":                now.Unix(),
+		"exp":                expires.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(d.key)
+	return token, expires, err
+}
+
+// IssueToken mints a development token for any subject and roles.  The name defaults to the subject.
+// Use the token as a bearer token on any other route.
+//
+// e.g. {"sub":"987654","userName":"Randy Goss","email":"randy@example.com","roles":["ADMIN"],"expiresIn":3600}
+//
+// PUBLIC API, only registered when DevAuth is enabled
+func (d *DevAuth) IssueToken(c echo.Context) error {
+	req := DevTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	req.Sub = strings.TrimSpace(req.Sub)
+	if req.Sub == "" || req.ExpiresIn < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "A sub and a non negative expiresIn are required")
+	}
+	if req.UserName == "" {
+		req.UserName = req.Sub
+	}
+	token, expires, err := d.Mint(req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, devToken{Token: token, ExpiresAt: expires})
+}
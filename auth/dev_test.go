@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	. "github.com/usace/microauth"
+)
+
+func TestMintedTokenVerifies(t *testing.T) {
+	var claims JwtClaim
+	mauth := Auth{
+		Aud: "nsi-survey",
+		AuthRoute: func(c echo.Context, authstore interface{}, roles []int, jc JwtClaim) bool {
+			claims = jc
+			return true
+		},
+	}
+	dev, err := NewDevAuth(&mauth, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewDevAuth(&Auth{Aud: "nsi-survey"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized := func(token string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		called := false
+		mauth.AuthorizeRoute(func(c echo.Context) error {
+			called = true
+			return c.String(http.StatusOK, "")
+		}, PUBLIC)(c)
+		return called
+	}
+
+	req := DevTokenRequest{Sub: "987654", UserName: "Randy Goss", Email: "randy@example.com", Roles: []string{"ADMIN"}}
+	token, expires, err := dev.Mint(req)
+	assert.NoError(t, err)
+	assert.True(t, expires.After(time.Now().Add(59*time.Minute)))
+	assert.True(t, authorized(token))
+	assert.Equal(t, "987654", claims.Sub)
+	assert.Equal(t, "Randy Goss", claims.UserName)
+	assert.Equal(t, []string{"ADMIN"}, claims.Roles)
+
+	forged, _, err := other.Mint(req)
+	assert.NoError(t, err)
+	assert.False(t, authorized(forged), "tokens signed by another key are rejected")
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo/v4"
+	. "github.com/usace/microauth"
+)
+
+// minKeyRefresh limits how often an unknown key id forces the key set to be reloaded
+const minKeyRefresh = time.Minute
+
+// RouteAuthorizer wraps a handler so it only runs for callers holding one of the route roles.  It is
+// satisfied by microauth.Auth, JwksAuth, and KeyAuth.
+type RouteAuthorizer interface {
+	AuthorizeRoute(handler echo.HandlerFunc, roles ...int) echo.HandlerFunc
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// parseJwks reads the RSA signing keys of a JWKS document by key id.  Other keys are ignored.
+func parseJwks(content []byte) (map[string]*rsa.PublicKey, error) {
+	set := jwkSet{}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid modulus: %s", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+		if err != nil {
+			return nil, fmt.Errorf("key %s has an invalid exponent: %s", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s has an invalid exponent", k.Kid)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS document has no RSA signing keys")
+	}
+	return keys, nil
+}
+
+// KeySet holds the verification keys of a JWKS document read from a file or an http(s) URL.  The document
+// is reloaded once the refresh interval passes, and sooner when a token names a key id it does not hold,
+// so keys rotated by the identity provider are picked up without a restart.  The last good keys are kept
+// when a reload fails.  A reload is claimed by setting tried, so concurrent callers do not fetch the
+// document together.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	loaded  time.Time
+	tried   time.Time
+}
+
+func NewKeySet(source string, refresh time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		tried:   time.Now(),
+	}
+	keys, err := ks.read()
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+	ks.loaded = ks.tried
+	return ks, nil
+}
+
+// read fetches and parses the JWKS document.  It does not hold ks.mu, so a slow identity provider does not
+// block tokens verified with keys already held.
+func (ks *KeySet) read() (map[string]*rsa.PublicKey, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(ks.source, "http://") || strings.HasPrefix(ks.source, "https://") {
+		content, err = ks.fetch()
+	} else {
+		content, err = ioutil.ReadFile(ks.source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseJwks(content)
+}
+
+func (ks *KeySet) fetch() ([]byte, error) {
+	resp, err := ks.client.Get(ks.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS request to %s returned %s", ks.source, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (ks *KeySet) reload() {
+	keys, err := ks.read()
+	if err != nil {
+		log.Printf("Error reloading JWKS from %s: %s", ks.source, err)
+		return
+	}
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.loaded = time.Now()
+	ks.mu.Unlock()
+}
+
+// Key returns the key with a key id.  Tokens without a key id are accepted when the set holds one key.
+func (ks *KeySet) Key(kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+	key, ok := ks.lookup(kid)
+	stale := ks.refresh > 0 && time.Since(ks.loaded) > ks.refresh
+	due := (stale || !ok) && time.Since(ks.tried) > minKeyRefresh
+	if due {
+		ks.tried = time.Now()
+	}
+	ks.mu.Unlock()
+	if due {
+		ks.reload()
+		ks.mu.Lock()
+		key, ok = ks.lookup(kid)
+		ks.mu.Unlock()
+	}
+	if ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (ks *KeySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	if key, ok := ks.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JwksAuth authorizes routes with RS256 tokens verified against a JWKS key set.  Tokens must carry an expiry,
+// be issued by one of the issuers, and name one of the audiences; an empty list accepts any.  Verified claims are passed
+// to AuthRoute as microauth does.
+type JwksAuth struct {
+	Keys      *KeySet
+	Issuers   []string
+	Audiences []string
+	AuthRoute func(c echo.Context, authstore interface{}, roles []int, claims JwtClaim) bool
+	Store     interface{}
+}
+
+func (a *JwksAuth) AuthorizeRoute(handler echo.HandlerFunc, roles ...int) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := a.verify(c.Request().Header.Get(echo.HeaderAuthorization))
+		if err != nil {
+			log.Printf("Rejected token: %s", err)
+			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+		}
+		if !a.AuthRoute(c, a.Store, roles, claims) {
+			return echo.NewHTTPError(http.StatusForbidden, "Forbidden")
+		}
+		return handler(c)
+	}
+}
+
+func (a *JwksAuth) verify(header string) (JwtClaim, error) {
+	claims := JwtClaim{}
+	raw := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if raw == "" || raw == header {
+		return claims, errors.New("missing bearer token")
+	}
+	parser := jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512"}}
+	token, err := parser.ParseWithClaims(raw, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return a.Keys.Key(kid)
+	})
+	if err != nil {
+		return claims, err
+	}
+	mc, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return claims, errors.New("unexpected claims")
+	}
+	if !mc.VerifyExpiresAt(time.Now().Unix(), true) {
+		return claims, errors.New("token has no expiry or has expired")
+	}
+	if err := checkIssuerAudience(mc, a.Issuers, a.Audiences); err != nil {
+		return claims, err
+	}
+	mc["aud"] = audiences(mc)
+	content, err := json.Marshal(mc)
+	if err != nil {
+		return claims, err
+	}
+	err = json.Unmarshal(content, &claims)
+	return claims, err
+}
+
+// checkIssuerAudience verifies the iss and aud claims of a token against the trusted values
+func checkIssuerAudience(claims map[string]interface{}, issuers []string, auds []string) error {
+	iss, _ := claims["iss"].(string)
+	if len(issuers) > 0 && !Contains_string(issuers, iss) {
+		return fmt.Errorf("untrusted issuer %q", iss)
+	}
+	if len(auds) == 0 {
+		return nil
+	}
+	for _, aud := range audiences(claims) {
+		if Contains_string(auds, aud) {
+			return nil
+		}
+	}
+	return errors.New("token is not intended for this audience")
+}
+
+// audiences returns the aud claim, which may be a single string or a list
+func audiences(claims map[string]interface{}) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	case []interface{}:
+		list := []string{}
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return []string{}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func rsaJwk(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func writeJwks(t *testing.T, path string, keys ...jwk) {
+	content, err := json.Marshal(jwkSet{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseJwks(t *testing.T) {
+	key := testKey(t)
+	enc := rsaJwk("enc", key)
+	enc.Use = "enc"
+	content, _ := json.Marshal(jwkSet{Keys: []jwk{rsaJwk("a", key), enc, {Kty: "EC", Kid: "ec"}}})
+	keys, err := parseJwks(content)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, 0, keys["a"].N.Cmp(key.N))
+	assert.Equal(t, key.E, keys["a"].E)
+	_, err = parseJwks([]byte(`{"keys":[]}`))
+	assert.Error(t, err)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	first, second := testKey(t), testKey(t)
+	writeJwks(t, path, rsaJwk("a", first))
+	ks, err := NewKeySet(path, time.Hour)
+	assert.NoError(t, err)
+	key, err := ks.Key("a")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, key.N.Cmp(first.N))
+	writeJwks(t, path, rsaJwk("b", second))
+	_, err = ks.Key("b")
+	assert.Error(t, err, "unknown keys only force a reload once per minKeyRefresh")
+	ks.tried = time.Now().Add(-2 * minKeyRefresh)
+	key, err = ks.Key("b")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, key.N.Cmp(second.N))
+}
+
+func TestKeySetWithoutKid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, rsaJwk("a", testKey(t)))
+	ks, err := NewKeySet(path, 0)
+	assert.NoError(t, err)
+	_, err = ks.Key("")
+	assert.NoError(t, err)
+	writeJwks(t, path, rsaJwk("a", testKey(t)), rsaJwk("b", testKey(t)))
+	ks, err = NewKeySet(path, 0)
+	assert.NoError(t, err)
+	_, err = ks.Key("")
+	assert.Error(t, err)
+}
+
+func TestCheckIssuerAudience(t *testing.T) {
+	claims := map[string]interface{}{"iss": "https://idp/realms/water", "aud": []interface{}{"account", "nsi-survey"}}
+	assert.NoError(t, checkIssuerAudience(claims, nil, nil))
+	assert.NoError(t, checkIssuerAudience(claims, []string{"https://idp/realms/other", "https://idp/realms/water"}, []string{"nsi-survey"}))
+	assert.Error(t, checkIssuerAudience(claims, []string{"https://idp/realms/other"}, nil))
+	assert.Error(t, checkIssuerAudience(claims, nil, []string{"other-app"}))
+	claims["aud"] = "nsi-survey"
+	assert.NoError(t, checkIssuerAudience(claims, nil, []string{"nsi-survey"}))
+	assert.Equal(t, []string{"nsi-survey"}, audiences(claims))
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	key := testKey(t)
+	writeJwks(t, path, rsaJwk("a", key))
+	ks, err := NewKeySet(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &JwksAuth{Keys: ks, Issuers: []string{"https://idp/realms/water"}, Audiences: []string{"nsi-survey"}}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://idp/realms/water",
+			"aud":   "nsi-survey",
+			"sub":   "987654",
+			"roles": []string{"ADMIN"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	sign := func(method jwt.SigningMethod, claims jwt.MapClaims, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "a"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	verified, err := a.verify(sign(jwt.SigningMethodRS256, claims(), key))
+	assert.NoError(t, err)
+	assert.Equal(t, "987654", verified.Sub)
+	assert.Equal(t, []string{"nsi-survey"}, verified.Aud)
+	assert.Equal(t, []string{"ADMIN"}, verified.Roles)
+
+	_, err = a.verify(sign(jwt.SigningMethodRS256, claims(), testKey(t)))
+	assert.Error(t, err, "signed by another key")
+	_, err = a.verify(sign(jwt.SigningMethodHS256, claims(), []byte("secret")))
+	assert.Error(t, err, "HS256")
+	_, err = a.verify(sign(jwt.SigningMethodNone, claims(), jwt.UnsafeAllowNoneSignatureType))
+	assert.Error(t, err, "no algorithm")
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = a.verify(sign(jwt.SigningMethodRS256, expired, key))
+	assert.Error(t, err, "expired")
+	unexpiring := claims()
+	delete(unexpiring, "exp")
+	_, err = a.verify(sign(jwt.SigningMethodRS256, unexpiring, key))
+	assert.Error(t, err, "no expiry")
+	_, err = a.verify("")
+	assert.Error(t, err, "no token")
+}
+
+func TestKeySetReloadDoesNotBlock(t *testing.T) {
+	key := testKey(t)
+	content, _ := json.Marshal(jwkSet{Keys: []jwk{rsaJwk("a", key)}})
+	release := make(chan struct{})
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			<-release
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+	defer close(release)
+	ks, err := NewKeySet(server.URL, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.tried = time.Now().Add(-2 * minKeyRefresh)
+	go ks.Key("a")
+	for {
+		ks.mu.Lock()
+		claimed := time.Since(ks.tried) < minKeyRefresh
+		ks.mu.Unlock()
+		if claimed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := ks.Key("a")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Key waited on a reload in progress")
+	}
+}
@@ -0,0 +1,72 @@
+package blobs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	root, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	bs, err := NewLocalBlobStore(root)
+	if assert.NoError(t, err) {
+		assert.NoError(t, bs.Put("a/b/c.txt", bytes.NewReader([]byte("evidence"))))
+		rc, err := bs.Get("a/b/c.txt")
+		if assert.NoError(t, err) {
+			content, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			assert.Equal(t, "evidence", string(content))
+		}
+		assert.NoError(t, bs.Delete("a/b/c.txt"))
+		_, err = bs.Get("a/b/c.txt")
+		assert.Error(t, err)
+		assert.NoError(t, bs.Delete("a/b/c.txt"))
+		assert.Equal(t, ErrInvalidKey, bs.Put("../escape", bytes.NewReader(nil)))
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	img.Set(10, 10, color.RGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := Thumbnail(&buf, ThumbnailSize)
+	if assert.NoError(t, err) {
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "jpeg", format)
+			assert.Equal(t, ThumbnailSize, cfg.Width)
+			assert.Equal(t, ThumbnailSize/2, cfg.Height)
+		}
+	}
+	_, err = Thumbnail(bytes.NewReader([]byte("not an image")), ThumbnailSize)
+	assert.Error(t, err)
+}
+
+func TestThumbnailRejectsOversizedImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// declare 100000x100000 pixels in the IHDR chunk of a tiny PNG and fix up its checksum
+	content := buf.Bytes()
+	binary.BigEndian.PutUint32(content[16:20], 100000)
+	binary.BigEndian.PutUint32(content[20:24], 100000)
+	binary.BigEndian.PutUint32(content[29:33], crc32.ChecksumIEEE(content[12:29]))
+	_, err := Thumbnail(bytes.NewReader(content), ThumbnailSize)
+	assert.Equal(t, ErrImageTooLarge, err)
+}
@@ -0,0 +1,62 @@
+package blobs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+const ThumbnailSize = 256
+
+// MaxImagePixels bounds the dimensions of images decoded for thumbnails, since a small compressed
+// upload can declare dimensions that need gigabytes of memory to decode
+const MaxImagePixels = 50000000
+
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// Thumbnail decodes a JPEG, PNG or GIF image and returns a JPEG encoded copy scaled so
+// that its longest side is at most maxDim pixels
+func Thumbnail(r io.Reader, maxDim int) ([]byte, error) {
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+	src, _, err := image.Decode(io.MultiReader(&header, r))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w >= h && w > maxDim {
+		tw, th = maxDim, h*maxDim/w
+	} else if h > w && h > maxDim {
+		tw, th = w*maxDim/h, maxDim
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := b.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*w/tw, sy))
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
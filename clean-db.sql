@@ -1,3 +1,18 @@
+drop table api_key_survey;
+drop table api_key;
+drop table service_account;
+drop table survey_group;
+drop table survey_invitation;
+drop table survey_territory_member;
+drop table survey_territory;
+drop table survey_setting;
+drop table idempotency_key;
+drop table survey_new_structure;
+drop sequence survey_new_structure_seq;
+drop table survey_flag;
+drop table survey_comment;
+drop table survey_attachment;
+drop table survey_domain;
 drop table survey_result;
 drop table survey_assignment;
 drop table survey_element;
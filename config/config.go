@@ -1,21 +1,44 @@
 package config
 
-import dq "github.com/usace/goquery"
+import (
+	"time"
+
+	dq "github.com/usace/goquery"
+)
 
 type Config struct {
-	SkipJWT       bool
-	LambdaContext bool
-	Dbuser        string
-	Dbpass        string
-	Dbname        string
-	Dbhost        string
-	Dbstore       string
-	Dbdriver      string
-	DBSSLMode     string
-	Dbport        string
-	Ippk          string
-	Port          string
-	Aud           string
+	LambdaContext     bool
+	Dbuser            string
+	Dbpass            string
+	Dbname            string
+	Dbhost            string
+	Dbstore           string
+	Dbdriver          string
+	DBSSLMode         string
+	Dbport            string
+	Ippk              string
+	Port              string
+	Aud               string
+	BlobRoot          string        `default:"./attachments"`
+	MaxAttachmentSize int64         `default:"10485760"`
+	RoleCacheTTL      time.Duration `default:"30s"`
+	DevAuth           bool
+	DevTokenTTL       time.Duration `default:"12h"`
+	Jwks              string
+	JwksRefresh       time.Duration `default:"15m"`
+	Issuers           []string
+	Audiences         []string
+}
+
+// TrustedAudiences returns the token audiences accepted with JWKS keys, defaulting to Aud
+func (c *Config) TrustedAudiences() []string {
+	if len(c.Audiences) > 0 {
+		return c.Audiences
+	}
+	if c.Aud != "" {
+		return []string{c.Aud}
+	}
+	return nil
 }
 
 func (c *Config) Rdbmsconfig() dq.RdbmsConfig {
@@ -3,4 +3,5 @@ package global
 const (
 	DB_NSI_SCHEMA    = "nsi_2022"
 	DB_NSI_TABLENAME = "nsi"
+	URL_PREFIX       = "nsisapi"
 )
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type purgeResult struct {
+	Result   string `json:"result"`
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+//Archives a survey.  Archived surveys are hidden from the survey list of their members and reject every change,
+//other than restoring, cloning, or purging them, with an HTTP CONFLICT (409) result.  Reports remain available to owners.
+//Returns an empty HTTP OK result on success.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) ArchiveSurvey(c echo.Context) error {
+	return sh.setArchived(c, true)
+}
+
+//Restores an archived survey.  Returns an empty HTTP OK result on success.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) RestoreSurvey(c echo.Context) error {
+	return sh.setArchived(c, false)
+}
+
+func (sh *SurveyHandler) setArchived(c echo.Context, archived bool) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	err = sh.store.SetArchived(surveyId, archived)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Survey not found")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
+
+//Permanently deletes an archived survey along with its members, elements, assignments, results, attachments,
+//comments, flags, new structures, and settings.  Surveys must be archived before they can be purged.
+//
+//snapshot: when true, the CSV survey report is written to the attachment store under snapshots/ before the survey is
+//deleted and its key is returned
+//
+//e.g. {"result":"purged","snapshot":"snapshots/{surveyid}-20240102T150405Z.csv"}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) PurgeSurvey(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	survey, err := sh.store.GetSurvey(surveyId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Survey not found")
+		}
+		return err
+	}
+	if !survey.Archived {
+		return echo.NewHTTPError(http.StatusConflict, "Survey must be archived before it is purged")
+	}
+	result := purgeResult{Result: "purged"}
+	if c.QueryParam("snapshot") == "true" {
+		results, links, err := sh.loadSurveyReport(surveyId)
+		if err != nil {
+			return err
+		}
+		var report bytes.Buffer
+		if err := writeSurveyReport(&report, results, links); err != nil {
+			return err
+		}
+		result.Snapshot = fmt.Sprintf("snapshots/%s-%s.csv", surveyId, time.Now().UTC().Format("20060102T150405Z"))
+		if err := sh.blobs.Put(result.Snapshot, &report); err != nil {
+			log.Printf("Error writing snapshot for survey %s: %s", surveyId, err)
+			return err
+		}
+	}
+	attachments, err := sh.store.GetSurveyAttachments(surveyId)
+	if err != nil {
+		return err
+	}
+	err = sh.store.PurgeSurvey(surveyId)
+	if err != nil {
+		log.Printf("Error purging survey %s: %s", surveyId, err)
+		return err
+	}
+	for _, a := range attachments {
+		for _, key := range []string{a.BlobKey, a.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := sh.blobs.Delete(key); err != nil {
+				log.Printf("Error deleting attachment content %s: %s", key, err)
+			}
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// ArchiveGuard rejects changes to archived surveys.  Requests that do not address a survey, reads, copies,
+// and the routes that restore or purge a survey pass through.  Fetching the next assignment is a read that
+// issues a new assignment, so it is guarded as a change.
+func (sh *SurveyHandler) ArchiveGuard(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		method := c.Request().Method
+		path := c.Path()
+		assigns := method == http.MethodGet && strings.HasSuffix(path, "/survey/:surveyid/assignment")
+		if (method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions) && !assigns {
+			return next(c)
+		}
+		if strings.HasSuffix(path, "/archive") || strings.HasSuffix(path, "/clone") || (method == http.MethodDelete && strings.HasSuffix(path, "/survey/:surveyid")) {
+			return next(c)
+		}
+		surveyId, err := uuid.Parse(c.Param("surveyid"))
+		if err != nil {
+			return next(c)
+		}
+		archived, err := sh.store.IsArchived(surveyId)
+		if err != nil {
+			log.Printf("Error checking whether survey %s is archived: %s", surveyId, err)
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "Unable to check the survey status")
+		}
+		if archived {
+			return echo.NewHTTPError(http.StatusConflict, "Survey is archived")
+		}
+		return next(c)
+	}
+}
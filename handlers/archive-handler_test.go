@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// guardTest runs a request for path through ArchiveGuard and reports the status and whether the handler ran
+func guardTest(h *SurveyHandler, method string, path string, surveyId uuid.UUID) (int, bool) {
+	_, c := surveyContext(method, "/", "", "987654", surveyId)
+	c.SetPath(path)
+	ran := false
+	err := h.ArchiveGuard(func(c echo.Context) error {
+		ran = true
+		return nil
+	})(c)
+	return httpStatus(err), ran
+}
+
+func setArchivedTest(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, archived bool) int {
+	method, handler := http.MethodPut, h.ArchiveSurvey
+	if !archived {
+		method, handler = http.MethodDelete, h.RestoreSurvey
+	}
+	rec, c := surveyContext(method, "/", "", "987654", surveyId)
+	if err := handler(c); err != nil {
+		return httpStatus(err)
+	}
+	return rec.Code
+}
+
+func TestArchiveGuard(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	archived, err := h.store.IsArchived(uuid.New())
+	if assert.NoError(t, err) {
+		assert.False(t, archived, "unknown surveys are not archived")
+	}
+
+	assert.Equal(t, http.StatusOK, setArchivedTest(t, h, surveyId, true))
+	archived, err = h.store.IsArchived(surveyId)
+	if assert.NoError(t, err) {
+		assert.True(t, archived)
+	}
+	code, ran := guardTest(h, http.MethodPost, "/nsisapi/survey/:surveyid/elements", surveyId)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.False(t, ran)
+	code, ran = guardTest(h, http.MethodGet, "/nsisapi/survey/:surveyid/assignment", surveyId)
+	assert.Equal(t, http.StatusConflict, code, "archived surveys issue no new assignments")
+	assert.False(t, ran)
+	for _, allowed := range []struct{ method, path string }{
+		{http.MethodGet, "/nsisapi/survey/:surveyid/report"},
+		{http.MethodDelete, "/nsisapi/survey/:surveyid/archive"},
+		{http.MethodPost, "/nsisapi/survey/:surveyid/clone"},
+		{http.MethodDelete, "/nsisapi/survey/:surveyid"},
+	} {
+		_, ran = guardTest(h, allowed.method, allowed.path, surveyId)
+		assert.True(t, ran, allowed.method+" "+allowed.path)
+	}
+
+	assert.Equal(t, http.StatusOK, setArchivedTest(t, h, surveyId, false))
+	_, ran = guardTest(h, http.MethodPost, "/nsisapi/survey/:surveyid/elements", surveyId)
+	assert.True(t, ran, "restored surveys accept changes")
+	assert.Equal(t, http.StatusNotFound, setArchivedTest(t, h, uuid.New(), true))
+}
+
+func TestGetSurveyReport(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	s, _ := assignTestStructure(t, h, surveyId, "987654")
+	_, err := h.store.SaveSurvey(&s, "987654", nil, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rec, c := surveyContext(http.MethodGet, "/", "", "987654", surveyId)
+	if assert.NoError(t, h.GetSurveyReport(c)) {
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-type"))
+		assert.Equal(t, "attachment; filename=surveys.csv", rec.Header().Get("Content-Disposition"))
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\r\n")
+		if assert.Len(t, lines, 2) {
+			assert.True(t, strings.HasPrefix(lines[0], "srId,"))
+			assert.Contains(t, lines[1], s.SAID.String())
+		}
+	}
+}
+
+func TestPurgeSurvey(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	s, _ := assignTestStructure(t, h, surveyId, "987654")
+	_, err := h.store.SaveSurvey(&s, "987654", nil, false)
+	assert.NoError(t, err)
+	purge := func() (int, purgeResult) {
+		rec, c := surveyContext(http.MethodDelete, "/?snapshot=true", "", "987654", surveyId)
+		result := purgeResult{}
+		if err := h.PurgeSurvey(c); err != nil {
+			return httpStatus(err), result
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return rec.Code, result
+	}
+	code, _ := purge()
+	assert.Equal(t, http.StatusConflict, code, "surveys must be archived before they are purged")
+
+	assert.Equal(t, http.StatusOK, setArchivedTest(t, h, surveyId, true))
+	code, result := purge()
+	if assert.Equal(t, http.StatusOK, code) {
+		assert.Equal(t, "purged", result.Result)
+		if assert.NotEmpty(t, result.Snapshot) {
+			snapshot, err := h.blobs.Get(result.Snapshot)
+			if assert.NoError(t, err) {
+				snapshot.Close()
+			}
+		}
+	}
+	_, err = h.store.GetSurvey(surveyId)
+	if assert.Error(t, err) {
+		assert.Equal(t, stores.NoResults, err.Error())
+	}
+	code, _ = purge()
+	assert.Equal(t, http.StatusNotFound, code)
+}
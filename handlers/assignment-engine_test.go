@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/usace/goquery"
+)
+
+// lastElementStrategy issues regular elements in reverse survey order
+type lastElementStrategy struct{}
+
+func (lastElementStrategy) NextElement(ds goquery.DataStore, tx *goquery.Tx, userId string, surveyId uuid.UUID) (*models.SurveyElement, error) {
+	elements := []models.SurveyElement{}
+	err := ds.Select(`select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where se.survey_id=$1 and se.is_control='false'
+					and not exists (select 1 from survey_assignment sa where sa.se_id=se.id)
+					order by se.survey_order desc limit 1
+					for update of se skip locked`).
+		Tx(tx).
+		Params(surveyId).
+		Dest(&elements).
+		Fetch()
+	if err != nil || len(elements) == 0 {
+		return nil, err
+	}
+	return &elements[0], nil
+}
+
+// assignedOrders issues elements to a user one at a time and returns their survey orders
+func assignedOrders(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string, n int) []int {
+	orders := []int{}
+	for i := 0; i < n; i++ {
+		assigned, err := h.store.AssignNext(userId, surveyId, 1)
+		if !assert.NoError(t, err) || len(assigned) == 0 {
+			break
+		}
+		orders = append(orders, elementOrder(t, assigned[0].SurveyElement_ID))
+	}
+	return orders
+}
+
+func elementOrder(t *testing.T, seId uuid.UUID) int {
+	var order int
+	err := getDataStore().Select("select survey_order from survey_element where id=$1").
+		Params(seId).
+		Dest(&order).
+		Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func setStrategy(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, strategy string, controlRate int) {
+	settings := models.DefaultSurveySettings(surveyId)
+	settings.AssignmentStrategy = strategy
+	settings.ControlRate = controlRate
+	if err := h.store.UpdateSurveySettings(settings); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectAssignmentStrategy(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	update := func(payload string) int {
+		rec, c := surveyContext(http.MethodPut, "/", payload, "987654", surveyId)
+		if err := h.UpdateSurveySettings(c); err != nil {
+			return httpStatus(err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnprocessableEntity, update(`{"assignmentStrategy":"alphabetical"}`))
+	assert.Equal(t, http.StatusOK, update(`{"assignmentStrategy":"random"}`))
+	settings, err := h.store.GetSurveySettings(surveyId)
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.StrategyRandom, settings.AssignmentStrategy)
+	}
+
+	stores.RegisterAssignmentStrategy("test_last", lastElementStrategy{})
+	assert.Equal(t, http.StatusOK, update(`{"assignmentStrategy":"test_last"}`))
+	assert.Equal(t, []int{9, 8, 6, 4}, assignedOrders(t, h, surveyId, "987654", 4))
+}
+
+func TestAssignNextSurveyOrder(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}, assignedOrders(t, h, surveyId, "987654", 10))
+	assert.Equal(t, []int{5, 7}, assignedOrders(t, h, surveyId, "987655", 10), "controls are issued to every member")
+}
+
+func TestAssignNextControlsByProgress(t *testing.T) {
+	for _, strategy := range []string{models.StrategyRandom, models.StrategyNearest, models.StrategyBlockCluster} {
+		h := buildHandler(t)
+		surveyId := createTestSurvey(t, h)
+		addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+		setStrategy(t, h, surveyId, strategy, 0)
+		assert.Len(t, assignedOrders(t, h, surveyId, "987655", 1), 1)
+
+		orders := assignedOrders(t, h, surveyId, "987654", 9)
+		controls := []int{}
+		for i, order := range orders {
+			if order == 5 || order == 7 {
+				controls = append(controls, i)
+			}
+		}
+		// regular elements 1-4 precede control 5 and 1-6 precede control 7, so each control is due once the
+		// member has been issued that many regular elements, whatever the survey's overall progress
+		assert.Equal(t, []int{4, 7}, controls, strategy)
+		assert.Len(t, orders, 8, strategy)
+	}
+}
+
+func TestAssignNextControlRate(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	setStrategy(t, h, surveyId, models.StrategyRandom, 2)
+	orders := assignedOrders(t, h, surveyId, "987654", 6)
+	if assert.Len(t, orders, 6) {
+		assert.Contains(t, []int{5, 7}, orders[2])
+		assert.Contains(t, []int{5, 7}, orders[5])
+	}
+}
+
+func TestAssignNextConcurrently(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	users := []string{"987654", "987655"}
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	for _, strategy := range []string{models.StrategySurveyOrder, models.StrategyRandom} {
+		setStrategy(t, h, surveyId, strategy, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(userId string) {
+				defer wg.Done()
+				_, err := h.store.AssignNext(userId, surveyId, 2)
+				assert.NoError(t, err)
+			}(users[i%2])
+		}
+		wg.Wait()
+	}
+	var duplicates int
+	err := getDataStore().Select(`select count(*) from (
+					select sa.se_id from survey_assignment sa
+					inner join survey_element se on se.id=sa.se_id
+					where se.survey_id=$1 and se.is_control='false'
+					group by sa.se_id having count(*) > 1) d`).
+		Params(surveyId).
+		Dest(&duplicates).
+		Fetch()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, duplicates, "regular elements are issued to a single member")
+	}
+}
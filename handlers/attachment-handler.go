@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/blobs"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/global"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+// content types accepted for upload, keyed by the sniffed type and flagged if a thumbnail can be generated
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"application/pdf": false,
+	"text/plain":      false,
+}
+
+// attachmentFormOverhead allows for the note and multipart framing around an uploaded file
+const attachmentFormOverhead = 1 << 20
+
+// attachmentFormMemory is the part of an upload form held in memory before parsing spools it to disk
+const attachmentFormMemory = 32 << 20
+
+//Uploads a photo or file as evidence for a survey assignment.  Expects a multipart form with an optional
+//"file" part and an optional "note" field; at least one must be provided.  Files are limited by size and
+//type (JPEG, PNG, GIF, PDF or plain text) and image uploads get a generated thumbnail.
+//Returns the attachment metadata with an HTTP CREATED (201) result on success.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles.  Members may only attach to their own assignments.
+func (sh *SurveyHandler) UploadAttachment(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	saId, err := uuid.Parse(c.Param("said"))
+	if err != nil {
+		return err
+	}
+	if _, err := sh.authorizeAssignment(c, surveyId, saId); err != nil {
+		return err
+	}
+	// bound the body before parsing the form so an oversized upload is refused instead of spooled to disk
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, sh.maxAttachmentSize+attachmentFormOverhead)
+	if err := req.ParseMultipartForm(attachmentFormMemory); err != nil && err != http.ErrNotMultipart {
+		if err.Error() == "http: request body too large" {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments are limited to %d bytes", sh.maxAttachmentSize))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid attachment form")
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	attachment := models.Attachment{
+		ID:         uuid.New(),
+		SAID:       saId,
+		Note:       c.FormValue("note"),
+		UploadedBy: claims.Sub,
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil && err != http.ErrMissingFile {
+		return err
+	}
+	if fh == nil {
+		if strings.TrimSpace(attachment.Note) == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "A file or note is required")
+		}
+		attachment.ContentType = "text/plain"
+	} else {
+		if fh.Size > sh.maxAttachmentSize {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments are limited to %d bytes", sh.maxAttachmentSize))
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		content, err := ioutil.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		contentType := strings.Split(http.DetectContentType(content), ";")[0]
+		thumbnail, ok := attachmentTypes[contentType]
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported attachment type %s", contentType))
+		}
+		attachment.FileName = filepath.Base(fh.Filename)
+		attachment.ContentType = contentType
+		attachment.Size = int64(len(content))
+		attachment.BlobKey = fmt.Sprintf("attachments/%s/%s/%s", surveyId, saId, attachment.ID)
+		if err := sh.blobs.Put(attachment.BlobKey, bytes.NewReader(content)); err != nil {
+			return err
+		}
+		if thumbnail {
+			thumb, err := blobs.Thumbnail(bytes.NewReader(content), blobs.ThumbnailSize)
+			if err != nil {
+				log.Printf("Unable to generate thumbnail for attachment %s: %s", attachment.ID, err)
+			} else {
+				attachment.ThumbnailKey = attachment.BlobKey + ".thumb.jpg"
+				if err := sh.blobs.Put(attachment.ThumbnailKey, bytes.NewReader(thumb)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := sh.store.InsertAttachment(attachment); err != nil {
+		log.Printf("Error saving attachment: %s", err)
+		sh.deleteAttachmentBlobs(attachment)
+		return err
+	}
+	return c.JSON(http.StatusCreated, attachmentLink(surveyId, attachment))
+}
+
+//Lists the attachments for a survey assignment. Returns a JSON array.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles.  Members may only list their own assignments.
+func (sh *SurveyHandler) GetAssignmentAttachments(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	saId, err := uuid.Parse(c.Param("said"))
+	if err != nil {
+		return err
+	}
+	if _, err := sh.authorizeAssignment(c, surveyId, saId); err != nil {
+		return err
+	}
+	attachments, err := sh.store.GetAssignmentAttachments(saId)
+	if err != nil {
+		return err
+	}
+	links := make([]models.AttachmentLink, len(attachments))
+	for i, a := range attachments {
+		links[i] = attachmentLink(surveyId, a)
+	}
+	return c.JSON(http.StatusOK, links)
+}
+
+//Returns the content of an attachment.  The query parameter thumbnail=true returns the generated thumbnail
+//for image attachments.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles.  Coordinators, reviewers, and viewers,
+//who can read the survey report, may read any attachment; other members only those of their own assignments.
+func (sh *SurveyHandler) GetAttachment(c echo.Context) error {
+	attachment, err := sh.surveyAttachment(c)
+	if err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	surveyId, _ := uuid.Parse(c.Param("surveyid"))
+	switch sh.store.GetMemberRole(surveyId, claims.Sub) {
+	case models.RoleCoordinator, models.RoleReviewer, models.RoleViewer:
+	default:
+		if _, err := sh.authorizeAssignment(c, surveyId, attachment.SAID); err != nil {
+			return err
+		}
+	}
+	key := attachment.BlobKey
+	contentType := attachment.ContentType
+	if c.QueryParam("thumbnail") == "true" {
+		key = attachment.ThumbnailKey
+		contentType = "image/jpeg"
+	}
+	if key == "" {
+		return echo.NewHTTPError(http.StatusNotFound, "Attachment has no content")
+	}
+	rc, err := sh.blobs.Get(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if disposition := mime.FormatMediaType("inline", map[string]string{"filename": attachment.FileName}); attachment.FileName != "" && disposition != "" {
+		c.Response().Header().Set("Content-Disposition", disposition)
+	}
+	return c.Stream(http.StatusOK, contentType, rc)
+}
+
+//Removes an attachment and its content. Returns an empty HTTP OK result on success.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles.  Members may only remove their own uploads.
+func (sh *SurveyHandler) DeleteAttachment(c echo.Context) error {
+	attachment, err := sh.surveyAttachment(c)
+	if err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	surveyId, _ := uuid.Parse(c.Param("surveyid"))
+	if attachment.UploadedBy != claims.Sub && !isAdmin(claims) && !sh.store.IsOwner(surveyId, claims.Sub) {
+		return echo.NewHTTPError(http.StatusForbidden, "Attachment belongs to another user")
+	}
+	if err := sh.store.DeleteAttachment(attachment.ID); err != nil {
+		return err
+	}
+	sh.deleteAttachmentBlobs(attachment)
+	return c.String(http.StatusOK, "")
+}
+
+func (sh *SurveyHandler) surveyAttachment(c echo.Context) (models.Attachment, error) {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachmentId, err := uuid.Parse(c.Param("attachmentid"))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	attachment, err := sh.store.GetAttachment(surveyId, attachmentId)
+	if err != nil && err.Error() == stores.NoResults {
+		return attachment, echo.NewHTTPError(http.StatusNotFound, "Attachment not found")
+	}
+	return attachment, err
+}
+
+func (sh *SurveyHandler) deleteAttachmentBlobs(attachment models.Attachment) {
+	for _, key := range []string{attachment.BlobKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := sh.blobs.Delete(key); err != nil {
+			log.Printf("Error removing attachment content %s: %s", key, err)
+		}
+	}
+}
+
+func attachmentLink(surveyId uuid.UUID, a models.Attachment) models.AttachmentLink {
+	link := models.AttachmentLink{
+		ID:          a.ID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		Note:        a.Note,
+	}
+	if a.BlobKey != "" {
+		link.Url = fmt.Sprintf("/%s/survey/%s/attachment/%s", global.URL_PREFIX, surveyId, a.ID)
+	}
+	if a.ThumbnailKey != "" {
+		link.ThumbnailUrl = link.Url + "?thumbnail=true"
+	}
+	return link
+}
+
+// surveyAttachmentLinks groups the attachment links for a survey by assignment
+func (sh *SurveyHandler) surveyAttachmentLinks(surveyId uuid.UUID) (map[uuid.UUID][]models.AttachmentLink, error) {
+	attachments, err := sh.store.GetSurveyAttachments(surveyId)
+	if err != nil {
+		return nil, err
+	}
+	links := map[uuid.UUID][]models.AttachmentLink{}
+	for _, a := range attachments {
+		links[a.SAID] = append(links[a.SAID], attachmentLink(surveyId, a))
+	}
+	return links, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// assignTestAttachment assigns user 987654 an element of a survey to attach to
+func assignTestAttachment(t *testing.T, h *SurveyHandler, surveyId uuid.UUID) uuid.UUID {
+	assigned, err := h.store.AssignNext("987654", surveyId, 1)
+	if err != nil || len(assigned) == 0 {
+		t.Fatalf("no assignment for 987654: %v", err)
+	}
+	return assigned[0].ID
+}
+
+// uploadTestAttachment posts a plain text file of the given size to an assignment
+func uploadTestAttachment(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, saId uuid.UUID, size int) (int, string) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "note.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(bytes.Repeat([]byte("a"), size))
+	form.Close()
+	rec, c := surveyContext(http.MethodPost, "/", body.String(), "987654", surveyId, "said", saId.String())
+	c.Request().Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	if err := h.UploadAttachment(c); err != nil {
+		return httpStatus(err), ""
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func TestUploadAttachmentBodyLimit(t *testing.T) {
+	h := buildHandler(t)
+	h.maxAttachmentSize = 1024
+	surveyId := createTestSurvey(t, h)
+	saId := assignTestAttachment(t, h, surveyId)
+
+	code, _ := uploadTestAttachment(t, h, surveyId, saId, 512)
+	assert.Equal(t, http.StatusCreated, code)
+	code, _ = uploadTestAttachment(t, h, surveyId, saId, 1024+attachmentFormOverhead)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code, "the body is refused before the form is parsed")
+	attachments, err := h.store.GetAssignmentAttachments(saId)
+	assert.NoError(t, err)
+	assert.Len(t, attachments, 1)
+}
+
+func TestGetAttachmentRoles(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	saId := assignTestAttachment(t, h, surveyId)
+	code, _ := uploadTestAttachment(t, h, surveyId, saId, 16)
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+	attachments, err := h.store.GetAssignmentAttachments(saId)
+	if err != nil || len(attachments) != 1 {
+		t.Fatalf("attachment not saved: %v", err)
+	}
+
+	tests := []struct {
+		role string
+		code int
+	}{
+		{models.RoleCoordinator, http.StatusOK},
+		{models.RoleReviewer, http.StatusOK},
+		{models.RoleViewer, http.StatusOK},
+		{models.RoleSurveyor, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		addTestMember(t, h, surveyId, "987655", test.role)
+		rec, c := surveyContext(http.MethodGet, "/", "", "987655", surveyId, "attachmentid", attachments[0].ID.String())
+		code := rec.Code
+		if err := h.GetAttachment(c); err != nil {
+			code = httpStatus(err)
+		}
+		assert.Equal(t, test.code, code, test.role)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	archiveManifestFile = "manifest.json"
+	archiveSurveyFile   = "survey.json"
+)
+
+type importResult struct {
+	Result   string         `json:"result"`
+	SurveyID *uuid.UUID     `json:"surveyId,omitempty"`
+	Counts   map[string]int `json:"counts"`
+}
+
+//Exports a complete survey as a zip archive for backup or transfer to another environment.  The archive holds a
+//manifest.json with the archive format version and record counts, and a survey.json with the survey, its members
+//and their users, elements, assignments, results (with their version history fields), settings, domains,
+//territories, comments, and flags.  Attachment content and new structure submissions are not included.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) ExportSurvey(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	archive, err := sh.store.ExportSurvey(surveyId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Survey not found")
+		}
+		return err
+	}
+	manifest := models.ArchiveManifest{
+		FormatVersion:  models.ArchiveFormatVersion,
+		ServerVersion:  version,
+		ExportedAt:     time.Now().UTC(),
+		SourceSurveyID: surveyId,
+		Counts:         archive.Counts(),
+	}
+	resp := c.Response()
+	resp.Header().Set("Content-type", "application/zip")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=survey-%s.zip", surveyId))
+	zw := zip.NewWriter(resp.Writer)
+	for name, content := range map[string]interface{}{archiveManifestFile: manifest, archiveSurveyFile: archive} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(w).Encode(content); err != nil {
+			log.Printf("Error writing survey archive: %s", err)
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+//Imports a survey archive created by ExportSurvey as a new survey.  The request is a multipart form with:
+//  archive: the zip archive
+//  users:   optional JSON object mapping user ids in the archive to user ids in this environment, e.g. {"987654":"123456"}
+//  title:   optional title for the new survey (defaults to the archived title)
+//Every record is given a new id.  Users that do not exist are created with their archived name.  The archive is checked
+//for referential integrity and failures are returned as an HTTP UNPROCESSABLE ENTITY (422) result listing each error.
+//
+//dryRun: when true, the import is performed and rolled back, reporting whether it would succeed
+//
+//e.g. {"result":"imported","surveyId":"...","counts":{"elements":1200,"results":845,...}}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) ImportSurvey(c echo.Context) error {
+	dryRun := c.QueryParam("dryRun") == "true"
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "An archive file is required")
+	}
+	users := map[string]string{}
+	if u := c.FormValue("users"); u != "" {
+		if err := json.Unmarshal([]byte(u), &users); err != nil {
+			return validationFailed(c, models.ValidationErrors{{Field: "users", Message: "must be a JSON object of user ids"}})
+		}
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := zip.NewReader(f, fh.Size)
+	if err != nil {
+		return validationFailed(c, models.ValidationErrors{{Field: "archive", Message: "is not a zip archive"}})
+	}
+	manifest := models.ArchiveManifest{}
+	archive := models.SurveyArchive{}
+	if err := readArchiveFile(zr, archiveManifestFile, &manifest); err != nil {
+		return validationFailed(c, models.ValidationErrors{{Field: archiveManifestFile, Message: err.Error()}})
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > models.ArchiveFormatVersion {
+		return validationFailed(c, models.ValidationErrors{{
+			Field:   "formatVersion",
+			Message: fmt.Sprintf("archive format version %d is not supported", manifest.FormatVersion),
+		}})
+	}
+	if err := readArchiveFile(zr, archiveSurveyFile, &archive); err != nil {
+		return validationFailed(c, models.ValidationErrors{{Field: archiveSurveyFile, Message: err.Error()}})
+	}
+	if title := c.FormValue("title"); title != "" {
+		archive.Survey.Title = title
+	}
+	if verrs := archive.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	archive = archive.Remap(users)
+	if verrs := archive.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	err = sh.store.ImportSurvey(archive, dryRun)
+	if err != nil {
+		log.Printf("Error importing survey archive: %s", err)
+		return err
+	}
+	result := importResult{Result: "valid", Counts: archive.Counts()}
+	if !dryRun {
+		result.Result = "imported"
+		result.SurveyID = &archive.Survey.ID
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+func readArchiveFile(zr *zip.Reader, name string, dest interface{}) error {
+	for _, zf := range zr.File {
+		if zf.Name != name {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(content, dest)
+	}
+	return errors.New("is missing from the archive")
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+//Copies a survey into a new survey and returns the new survey id with an HTTP CREATED (201) result on success.
+//Everything is copied by default; the payload chooses what to leave out or regenerate:
+//  title:        the new survey title (required)
+//  description:  the new survey description (defaults to the source description)
+//  members:      all, owners, or none
+//  elements:     copy the survey elements (no assignments or results are copied)
+//  order:        keep the survey order or regenerate it at random
+//  controls:     keep the control designation, make every element regular (none), or designate controlCount random controls
+//  settings:     copy the assignment settings, survey specific domains, and territories
+//The user making the copy is always an owner of the new survey.
+//
+//e.g. {"title":"Jefferson Parish 2024","members":"all","elements":true,"order":"random","controls":"random","controlCount":25}
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) CloneSurvey(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	source, err := sh.store.GetSurvey(surveyId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Survey not found")
+		}
+		return err
+	}
+	clone := models.DefaultSurveyClone(source)
+	if err := c.Bind(&clone); err != nil {
+		return err
+	}
+	if verrs := clone.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	newId, err := sh.store.CloneSurvey(surveyId, clone, claims.Sub)
+	if err != nil {
+		log.Printf("Error cloning survey %s: %s", surveyId, err)
+		return err
+	}
+	return c.JSONBlob(http.StatusCreated, []byte(fmt.Sprintf(`{"surveyId":"%s"}`, newId)))
+}
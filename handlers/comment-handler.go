@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+type commentRequest struct {
+	Body     string     `json:"body"`
+	ParentID *uuid.UUID `json:"parentId"`
+}
+
+type flagRequest struct {
+	FlagType string `json:"flagType"`
+	Note     string `json:"note"`
+}
+
+type resolveRequest struct {
+	Resolution string `json:"resolution"`
+}
+
+//Adds a comment to a survey element.  Replies to an existing comment on the same element set parentId.
+//Returns the new comment as JSON with an HTTP CREATED (201) result on success.
+//
+//e.g. {"body":"this fd_id is actually two buildings","parentId":null}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_REVIEWER, and SURVEY_MEMBER roles.  Members comment through their own assignments.
+func (sh *SurveyHandler) AddComment(c echo.Context) error {
+	surveyId, seId, saId, err := sh.elementTarget(c)
+	if err != nil {
+		return err
+	}
+	req := commentRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		return validationFailed(c, models.ValidationErrors{{Field: "body", Message: "is required"}})
+	}
+	if req.ParentID != nil {
+		parent, err := sh.store.GetComment(*req.ParentID)
+		if err != nil || parent.SEID != seId {
+			return validationFailed(c, models.ValidationErrors{{Field: "parentId", Message: "is not a comment on this element"}})
+		}
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	id, err := sh.store.InsertComment(models.Comment{
+		SurveyID: surveyId,
+		SEID:     seId,
+		SAID:     saId,
+		ParentID: req.ParentID,
+		UserID:   claims.Sub,
+		Body:     req.Body,
+	})
+	if err != nil {
+		return err
+	}
+	comment, err := sh.store.GetComment(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, comment)
+}
+
+//Lists the comments on a survey element in the order they were made. Returns a JSON array.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_REVIEWER, and SURVEY_MEMBER roles.  Members read comments through their own assignments.
+func (sh *SurveyHandler) GetComments(c echo.Context) error {
+	_, seId, _, err := sh.elementTarget(c)
+	if err != nil {
+		return err
+	}
+	comments, err := sh.store.GetElementComments(seId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, comments)
+}
+
+//Flags a survey element for owner attention.  flagType must be one of duplicate, not_a_structure,
+//wrong_location, or needs_review.  Returns the new flag id with an HTTP CREATED (201) result on success.
+//
+//e.g. {"flagType":"wrong_location","note":"location is in a lake"}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_REVIEWER, and SURVEY_MEMBER roles.  Members flag through their own assignments.
+func (sh *SurveyHandler) RaiseFlag(c echo.Context) error {
+	surveyId, seId, saId, err := sh.elementTarget(c)
+	if err != nil {
+		return err
+	}
+	req := flagRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if !models.ValidFlagType(req.FlagType) {
+		return validationFailed(c, models.ValidationErrors{{Field: "flagType", Message: req.FlagType + " is not a valid value"}})
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	id, err := sh.store.InsertFlag(models.Flag{
+		SurveyID: surveyId,
+		SEID:     seId,
+		SAID:     saId,
+		FlagType: req.FlagType,
+		Note:     req.Note,
+		RaisedBy: claims.Sub,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, map[string]uuid.UUID{"flagId": id})
+}
+
+//Lists every flag, open or resolved, on a survey element. Returns a JSON array.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_REVIEWER, and SURVEY_MEMBER roles.  Members read flags through their own assignments.
+func (sh *SurveyHandler) GetElementFlags(c echo.Context) error {
+	_, seId, _, err := sh.elementTarget(c)
+	if err != nil {
+		return err
+	}
+	flags, err := sh.store.GetElementFlags(seId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, flags)
+}
+
+//Owner inbox listing the flags raised on a survey. Returns a JSON array.  This method takes two optional query parameters:
+//
+//status: open (default), resolved, or all
+//
+//type: limit the list to a single flag type
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetSurveyFlags(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	var resolved *bool
+	switch c.QueryParam("status") {
+	case "", "open":
+		resolved = new(bool)
+	case "resolved":
+		resolved = new(bool)
+		*resolved = true
+	case "all":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+	}
+	flags, err := sh.store.GetSurveyFlags(surveyId, resolved, c.QueryParam("type"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, flags)
+}
+
+//Resolves an open flag, recording who resolved it, when, and how. Returns an empty HTTP OK result on success.
+//
+//e.g. {"resolution":"element removed from survey"}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_REVIEWER roles
+func (sh *SurveyHandler) ResolveFlag(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	flagId, err := uuid.Parse(c.Param("flagid"))
+	if err != nil {
+		return err
+	}
+	req := resolveRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	err = sh.store.ResolveFlag(surveyId, flagId, claims.Sub, req.Resolution)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Open flag not found")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
+
+// elementTarget resolves the survey element addressed by the url.  Routes with a said parameter
+// address the element of an assignment the caller may work on; routes with a seid parameter address
+// the element directly and are reserved for survey managers by the route authorization.
+func (sh *SurveyHandler) elementTarget(c echo.Context) (uuid.UUID, uuid.UUID, *uuid.UUID, error) {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return surveyId, uuid.Nil, nil, err
+	}
+	if c.Param("said") != "" {
+		saId, err := uuid.Parse(c.Param("said"))
+		if err != nil {
+			return surveyId, uuid.Nil, nil, err
+		}
+		sa, err := sh.authorizeAssignment(c, surveyId, saId)
+		if err != nil {
+			return surveyId, uuid.Nil, nil, err
+		}
+		return surveyId, sa.SurveyElement_ID, &saId, nil
+	}
+	seId, err := uuid.Parse(c.Param("seid"))
+	if err != nil {
+		return surveyId, uuid.Nil, nil, err
+	}
+	if _, err := sh.store.GetSurveyElement(surveyId, seId); err != nil {
+		if err.Error() == stores.NoResults {
+			return surveyId, seId, nil, echo.NewHTTPError(http.StatusNotFound, "Element not found")
+		}
+		return surveyId, seId, nil, err
+	}
+	return surveyId, seId, nil, nil
+}
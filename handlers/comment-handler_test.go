@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func addTestComment(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, seId uuid.UUID, payload string) (int, models.Comment) {
+	rec, c := surveyContext(http.MethodPost, "/", payload, "987654", surveyId, "seid", seId.String())
+	comment := models.Comment{}
+	if assert.NoError(t, h.AddComment(c)) && rec.Code == http.StatusCreated {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &comment))
+	}
+	return rec.Code, comment
+}
+
+func TestCommentThreading(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	first := testElement(t, surveyId, 1)
+	second := testElement(t, surveyId, 2)
+
+	code, root := addTestComment(t, h, surveyId, first.ID, `{"body":"this fd_id is actually two buildings"}`)
+	assert.Equal(t, http.StatusCreated, code)
+	code, reply := addTestComment(t, h, surveyId, first.ID, fmt.Sprintf(`{"body":"agreed","parentId":"%s"}`, root.ID))
+	if assert.Equal(t, http.StatusCreated, code) && assert.NotNil(t, reply.ParentID) {
+		assert.Equal(t, root.ID, *reply.ParentID)
+	}
+	code, _ = addTestComment(t, h, surveyId, second.ID, fmt.Sprintf(`{"body":"wrong thread","parentId":"%s"}`, root.ID))
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "replies must answer a comment on the same element")
+	code, _ = addTestComment(t, h, surveyId, first.ID, fmt.Sprintf(`{"body":"missing parent","parentId":"%s"}`, uuid.New()))
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, _ = addTestComment(t, h, surveyId, first.ID, `{"body":"  "}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+
+	rec, c := surveyContext(http.MethodGet, "/", "", "987654", surveyId, "seid", first.ID.String())
+	if assert.NoError(t, h.GetComments(c)) {
+		comments := []models.Comment{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &comments))
+		if assert.Len(t, comments, 2) {
+			assert.Equal(t, root.ID, comments[0].ID)
+			assert.Nil(t, comments[0].ParentID)
+			assert.Equal(t, reply.ID, comments[1].ID)
+		}
+	}
+}
+
+func raiseTestFlag(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, seId uuid.UUID, flagType string) (int, uuid.UUID) {
+	rec, c := surveyContext(http.MethodPost, "/", fmt.Sprintf(`{"flagType":"%s","note":"test"}`, flagType), "987654", surveyId, "seid", seId.String())
+	created := map[string]uuid.UUID{}
+	if assert.NoError(t, h.RaiseFlag(c)) && rec.Code == http.StatusCreated {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	}
+	return rec.Code, created["flagId"]
+}
+
+func TestRaiseFlagType(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	se := testElement(t, surveyId, 1)
+	code, _ := raiseTestFlag(t, h, surveyId, se.ID, "haunted")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, id := raiseTestFlag(t, h, surveyId, se.ID, models.FlagDuplicate)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.NotEqual(t, uuid.Nil, id)
+}
+
+func TestSurveyFlagFilters(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	se := testElement(t, surveyId, 1)
+	_, resolvedId := raiseTestFlag(t, h, surveyId, se.ID, models.FlagWrongLocation)
+	raiseTestFlag(t, h, surveyId, se.ID, models.FlagDuplicate)
+	raiseTestFlag(t, h, surveyId, se.ID, models.FlagNeedsReview)
+
+	rec, c := surveyContext(http.MethodPut, "/", `{"resolution":"moved"}`, "987654", surveyId, "flagid", resolvedId.String())
+	if assert.NoError(t, h.ResolveFlag(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	_, c = surveyContext(http.MethodPut, "/", `{"resolution":"again"}`, "987654", surveyId, "flagid", resolvedId.String())
+	assert.Equal(t, http.StatusNotFound, httpStatus(h.ResolveFlag(c)), "resolved flags cannot be resolved again")
+
+	listFlags := func(query string) []models.Flag {
+		rec, c := surveyContext(http.MethodGet, "/?"+query, "", "987654", surveyId)
+		flags := []models.Flag{}
+		if assert.NoError(t, h.GetSurveyFlags(c)) {
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &flags))
+		}
+		return flags
+	}
+	open := listFlags("")
+	assert.Len(t, open, 2)
+	for _, f := range open {
+		assert.False(t, f.Resolved)
+	}
+	assert.Len(t, listFlags("status=open"), 2)
+	resolved := listFlags("status=resolved")
+	if assert.Len(t, resolved, 1) {
+		assert.Equal(t, resolvedId, resolved[0].ID)
+		assert.Equal(t, "moved", resolved[0].Resolution)
+	}
+	assert.Len(t, listFlags("status=all"), 3)
+	duplicates := listFlags("status=all&type=" + models.FlagDuplicate)
+	if assert.Len(t, duplicates, 1) {
+		assert.Equal(t, models.FlagDuplicate, duplicates[0].FlagType)
+	}
+
+	_, c = surveyContext(http.MethodGet, "/?status=closed", "", "987654", surveyId)
+	assert.Equal(t, http.StatusBadRequest, httpStatus(h.GetSurveyFlags(c)))
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+//Returns the default data dictionary as a JSON array.  Each attribute includes the NSI column name,
+//the SurveyStructure JSON field name, label, type, unit, and allowed values with descriptions.
+//
+//PUBLIC API
+func (sh *SurveyHandler) GetDictionary(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.StructureAttributes)
+}
+
+//Returns the data dictionary for a survey as a JSON array.  Survey specific domain values replace
+//the defaults for any attribute that has them.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles
+func (sh *SurveyHandler) GetSurveyDictionary(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	attributes, err := sh.store.GetSurveyDictionary(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, attributes)
+}
+
+//Replaces the allowed values of a coded attribute for a survey.  The attribute is identified by its
+//NSI column name and the payload is a JSON array of values.  An empty array restores the default domain.
+//Returns an empty HTTP OK result on success.
+//
+//e.g. [{"code":"RES1","description":"Single Family Dwelling"},{"code":"RES2","description":"Manufactured Housing"}]
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) UpdateSurveyDomain(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	column := c.Param("column")
+	attr, ok := models.FindAttribute(models.StructureAttributes, column)
+	if !ok || len(attr.Values) == 0 {
+		return errors.New("Invalid Request")
+	}
+	values := []models.DomainValue{}
+	if err := c.Bind(&values); err != nil {
+		return err
+	}
+	verrs := models.ValidationErrors{}
+	seen := map[string]bool{}
+	for i, v := range values {
+		field := fmt.Sprintf("%s[%d]", attr.Field, i)
+		switch {
+		case v.Code == "":
+			verrs = append(verrs, models.FieldError{Field: field, Message: "code is required"})
+		case attr.MaxLength > 0 && len(v.Code) > attr.MaxLength:
+			verrs = append(verrs, models.FieldError{Field: field, Message: fmt.Sprintf("code exceeds the maximum length of %d", attr.MaxLength)})
+		case seen[v.Code]:
+			verrs = append(verrs, models.FieldError{Field: field, Message: fmt.Sprintf("duplicate code %s", v.Code)})
+		}
+		seen[v.Code] = true
+	}
+	if len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	err = sh.store.SetSurveyDomain(surveyId, column, values)
+	if err != nil {
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyDomainKeepsOrder(t *testing.T) {
+	h := buildHandler(t)
+	var surveyId uuid.UUID
+	err := getDataStore().Select("insert into survey (title,description,active) values ('Dictionary Test','',true) returning id").
+		Dest(&surveyId).
+		Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []models.DomainValue{
+		{Code: "S", Description: "Slab"},
+		{Code: "C", Description: "Crawl"},
+		{Code: "P", Description: "Pile"},
+	}
+	assert.NoError(t, h.store.SetSurveyDomain(surveyId, "found_type", values))
+	attrs, err := h.store.GetSurveyDictionary(surveyId)
+	assert.NoError(t, err)
+	attr, ok := models.FindAttribute(attrs, "found_type")
+	assert.True(t, ok)
+	assert.Equal(t, values, attr.Values)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// assignTestStructure assigns the next element of a survey to a user and loads its structure along with the
+// element id
+func assignTestStructure(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string) (models.SurveyStructure, uuid.UUID) {
+	assigned, err := h.store.AssignNext(userId, surveyId, 1)
+	if err != nil || len(assigned) == 0 {
+		t.Fatalf("no assignment for %s: %v", userId, err)
+	}
+	s, err := h.store.GetStructure(assigned[0].SurveyElement_ID, assigned[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, assigned[0].SurveyElement_ID
+}
+
+func saveTestDraft(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, s models.SurveyStructure) int {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, c := surveyContext(http.MethodPut, "/", string(payload), "987654", surveyId)
+	err = h.SaveSurveyAssignmentDraft(c)
+	if err != nil {
+		return httpStatus(err)
+	}
+	return rec.Code
+}
+
+func TestSaveDraftKeepsAssignmentOpen(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	s, seId := assignTestStructure(t, h, surveyId, "987654")
+	s.FoundHt = 2.5
+	assert.Equal(t, http.StatusOK, saveTestDraft(t, h, surveyId, s))
+
+	drafts, err := h.store.GetMemberAssignments("987654", surveyId, "draft", 10, 0)
+	if assert.NoError(t, err) && assert.Len(t, drafts, 1) {
+		assert.Equal(t, s.SAID, drafts[0].SAID)
+		assert.False(t, drafts[0].Completed)
+	}
+	saved, err := h.store.GetStructure(seId, s.SAID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2.5, saved.FoundHt)
+	}
+}
+
+func TestSaveDraftAfterSubmit(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	s, seId := assignTestStructure(t, h, surveyId, "987654")
+	s.FoundHt = 9.5
+	s.NoStreetView = true
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, c := surveyContext(http.MethodPost, "/", string(payload), "987654", surveyId)
+	if assert.NoError(t, h.SaveSurveyAssignment(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	s.FoundHt = 1
+	assert.Equal(t, http.StatusConflict, saveTestDraft(t, h, surveyId, s), "a draft must not overwrite a submitted result")
+	_, err = h.store.SaveDraft(&s, "987654", nil)
+	assert.Equal(t, stores.ErrAssignmentSubmitted, err)
+	saved, err := h.store.GetStructure(seId, s.SAID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9.5, saved.FoundHt)
+	}
+}
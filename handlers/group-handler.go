@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+//Lists the identity provider groups bound to a survey as a JSON array, with the number of members each group has
+//granted.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) GetSurveyGroups(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	groups, err := sh.store.GetSurveyGroups(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+//Binds an identity provider group to a survey, or changes the role of a bound group.  Any user whose token roles
+//include the group becomes a member with the group's role when they next authenticate, and loses the membership
+//once they no longer carry the group.  Members added directly keep their own role, and adding or updating a group
+//member directly takes the membership out of the group's control.  Changing the role updates the members the group
+//has already granted.  Returns the bound groups as JSON on success.
+//
+//e.g. PUT /survey/{surveyid}/groups/nsi-field-crew {"role":"surveyor"}
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) UpsertSurveyGroup(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	group := models.SurveyGroup{}
+	if err := c.Bind(&group); err != nil {
+		return err
+	}
+	group.SurveyID = surveyId
+	group.Group = c.Param("group")
+	if verrs := group.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	if err := sh.store.UpsertSurveyGroup(group); err != nil {
+		if err == stores.ErrLastOwner {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return err
+	}
+	groups, err := sh.store.GetSurveyGroups(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+//Unbinds an identity provider group from a survey and removes the memberships it granted.  The assignments query
+//parameter says what happens to the open assignments of those members:
+//
+//keep: the assignments stay with the users (the default)
+//release: the assignments, their draft results, and their attachments are deleted and the elements can be issued again
+//
+//e.g. DELETE /survey/{surveyid}/groups/nsi-field-crew?assignments=release
+//
+//Returns the number of assignments released and kept.  A group that granted the survey's last owner cannot be
+//removed.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) DeleteSurveyGroup(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	removal := models.MemberRemoval{Assignments: c.QueryParam("assignments")}
+	switch removal.Assignments {
+	case "":
+		removal.Assignments = models.KeepAssignments
+	case models.KeepAssignments, models.ReleaseAssignments:
+	default:
+		return validationFailed(c, models.ValidationErrors{{Field: "assignments",
+			Message: fmt.Sprintf("must be one of %s or %s", models.KeepAssignments, models.ReleaseAssignments)}})
+	}
+	result, err := sh.store.DeleteSurveyGroup(surveyId, c.Param("group"), removal)
+	if err != nil {
+		switch {
+		case err.Error() == stores.NoResults:
+			return echo.NewHTTPError(http.StatusNotFound, "Group is not bound to the survey")
+		case err == stores.ErrLastOwner:
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return err
+	}
+	for _, a := range result.Attachments {
+		sh.deleteAttachmentBlobs(a)
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+// Idempotent wraps a handler so that a client retrying a request with the same Idempotency-Key header
+// receives the original response, including its ETag, instead of repeating the operation.  Keys are scoped
+// to the requesting user and reusing a key with a different request is rejected.  Requests that fail with an
+// error are not recorded so they can be retried.  Must be wrapped by the route authorization so the user is known.
+func (sh *SurveyHandler) Idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(idempotencyHeader)
+		if key == "" {
+			return next(c)
+		}
+		if len(key) > 100 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Idempotency-Key is limited to 100 characters")
+		}
+		claims := c.Get("NSIUSER").(microauth.JwtClaim)
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		c.Request().Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		hash.Write([]byte(c.Request().Method + " " + c.Request().URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		reserved, stored, err := sh.store.ReserveIdempotencyKey(claims.Sub, key, requestHash)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			switch {
+			case stored.RequestHash != requestHash:
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+			case stored.StatusCode == 0:
+				return echo.NewHTTPError(http.StatusConflict, "A request with this Idempotency-Key is in progress")
+			}
+			c.Response().Header().Set("Idempotent-Replayed", "true")
+			if stored.ETag != "" {
+				c.Response().Header().Set("ETag", stored.ETag)
+			}
+			return c.Blob(stored.StatusCode, stored.ContentType, []byte(stored.Response))
+		}
+
+		resp := c.Response()
+		recorder := &responseRecorder{ResponseWriter: resp.Writer}
+		resp.Writer = recorder
+		err = next(c)
+		resp.Writer = recorder.ResponseWriter
+		if err != nil || resp.Status >= http.StatusInternalServerError {
+			if rerr := sh.store.ReleaseIdempotencyKey(claims.Sub, key); rerr != nil {
+				log.Printf("Error releasing idempotency key: %s", rerr)
+			}
+			return err
+		}
+		cerr := sh.store.CompleteIdempotencyKey(claims.Sub, key, models.IdempotentResponse{
+			StatusCode:  resp.Status,
+			Response:    recorder.body.String(),
+			ContentType: resp.Header().Get(echo.HeaderContentType),
+			ETag:        resp.Header().Get("ETag"),
+		})
+		if cerr != nil {
+			log.Printf("Error recording idempotent response: %s", cerr)
+		}
+		return nil
+	}
+}
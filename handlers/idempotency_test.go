@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func idempotentTest(h *SurveyHandler, next echo.HandlerFunc, key string, payload string) (int, string, http.Header) {
+	rec, c := surveyContext(http.MethodPost, "/", payload, "987654", uuid.New())
+	c.Request().Header.Set(idempotencyHeader, key)
+	if err := h.Idempotent(next)(c); err != nil {
+		return httpStatus(err), "", rec.Header()
+	}
+	return rec.Code, rec.Body.String(), rec.Header()
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	h := buildHandler(t)
+	calls := 0
+	next := func(c echo.Context) error {
+		calls++
+		setETag(c, calls)
+		return c.String(http.StatusCreated, `{"call":1}`)
+	}
+	key := uuid.New().String()
+	code, body, header := idempotentTest(h, next, key, `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Empty(t, header.Get("Idempotent-Replayed"))
+	code, replayed, header := idempotentTest(h, next, key, `{"a":1}`)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, body, replayed)
+	assert.Equal(t, "true", header.Get("Idempotent-Replayed"))
+	assert.Equal(t, `"1"`, header.Get("ETag"), "a replay carries the original version")
+	assert.Equal(t, 1, calls, "a replayed request must not repeat the operation")
+
+	code, _, _ = idempotentTest(h, next, key, `{"a":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "a key cannot be reused for a different request")
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	h := buildHandler(t)
+	key := uuid.New().String()
+	concurrent := 0
+	var next echo.HandlerFunc
+	next = func(c echo.Context) error {
+		// a retry arriving while the first request is still running
+		concurrent, _, _ = idempotentTest(h, next, key, `{"a":1}`)
+		return c.String(http.StatusOK, `{"result":"success"}`)
+	}
+	code, _, _ := idempotentTest(h, next, key, `{"a":1}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusConflict, concurrent)
+
+	reserved, stored, err := h.store.ReserveIdempotencyKey("987654", key, "")
+	if assert.NoError(t, err) {
+		assert.False(t, reserved, "a key can only be reserved once")
+		assert.Equal(t, http.StatusOK, stored.StatusCode)
+	}
+}
+
+func TestIdempotentReleasesFailures(t *testing.T) {
+	h := buildHandler(t)
+	calls := 0
+	next := func(c echo.Context) error {
+		calls++
+		if calls == 1 {
+			return errors.New("transient failure")
+		}
+		return c.String(http.StatusOK, `{"result":"success"}`)
+	}
+	key := uuid.New().String()
+	idempotentTest(h, next, key, `{}`)
+	code, body, _ := idempotentTest(h, next, key, `{}`)
+	assert.Equal(t, http.StatusOK, code, "failed requests can be retried with the same key")
+	assert.Equal(t, `{"result":"success"}`, body)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		version *int
+		code    int
+	}{
+		{"", nil, 0},
+		{"*", nil, 0},
+		{`"3"`, intPtr(3), 0},
+		{`W/"4"`, intPtr(4), 0},
+		{`"three"`, nil, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		_, c := surveyContext(http.MethodPost, "/", "", "987654", uuid.New())
+		c.Request().Header.Set("If-Match", test.header)
+		version, err := ifMatchVersion(c)
+		assert.Equal(t, test.code, httpStatus(err), test.header)
+		assert.Equal(t, test.version, version, test.header)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestVersionConflict(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	s, _ := assignTestStructure(t, h, surveyId, "987654")
+	version, err := h.store.SaveDraft(&s, "987654", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	stale := version - 1
+	_, err = h.store.SaveDraft(&s, "987654", &stale)
+	assert.Equal(t, stores.ErrVersionConflict, err)
+
+	s.FoundHt = 6
+	payload, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, c := surveyContext(http.MethodPost, "/", string(payload), "987654", surveyId)
+	c.Request().Header.Set("If-Match", `"0"`)
+	if assert.NoError(t, h.SaveSurveyAssignment(c)) {
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		body := map[string]json.RawMessage{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, `"conflict"`, string(body["result"]))
+		assert.NotEmpty(t, body["current"])
+		assert.NotEmpty(t, body["submitted"])
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+	}
+
+	etag := rec.Header().Get("ETag")
+	rec, c = surveyContext(http.MethodPost, "/", string(payload), "987654", surveyId)
+	c.Request().Header.Set("If-Match", etag)
+	if assert.NoError(t, h.SaveSurveyAssignment(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
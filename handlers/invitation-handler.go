@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+//Invites a user to a survey before they have logged in.  The invitation names the user by email, by the subject
+//of their identity provider token, or both, and is accepted the first time a user whose token matches
+//authenticates, adding them to the survey with the invited role.  Invitations expire after expiresInDays
+//(default 14, at most 90).  Only owners may invite owners.  Owner and coordinator invitations must name the subject
+//since token emails are not necessarily verified.
+//Returns the new invitation as JSON with an HTTP CREATED (201) result on success.
+//
+//e.g. {"email":"crew.lead@example.com","subject":"987654","role":"coordinator","expiresInDays":30}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) CreateInvitation(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	invitation := models.Invitation{}
+	if err := c.Bind(&invitation); err != nil {
+		return err
+	}
+	if verrs := invitation.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	if invitation.Role == "" {
+		invitation.Role = models.RoleSurveyor
+	}
+	if invitation.Role == models.RoleOwner && !isAdmin(claims) && !sh.store.IsOwner(surveyId, claims.Sub) {
+		return echo.NewHTTPError(http.StatusForbidden, "Only owners may invite owners")
+	}
+	if invitation.ExpiresInDays == 0 {
+		invitation.ExpiresInDays = models.DefaultInvitationDays
+	}
+	invitation.SurveyID = surveyId
+	invitation.InvitedBy = claims.Sub
+	id, err := sh.store.InsertInvitation(invitation)
+	if err != nil {
+		return err
+	}
+	invitation, err = sh.store.GetInvitation(surveyId, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+//Lists the invitations of a survey as a JSON array, newest first.
+//
+//status: optional, one of pending, accepted, expired, or revoked
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) GetInvitations(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	status := c.QueryParam("status")
+	switch status {
+	case "", models.InvitationPending, models.InvitationAccepted, models.InvitationExpired, models.InvitationRevoked:
+	default:
+		return validationFailed(c, models.ValidationErrors{{Field: "status", Message: status + " is not a valid invitation status"}})
+	}
+	invitations, err := sh.store.GetInvitations(surveyId, status)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, invitations)
+}
+
+//Revokes a pending invitation.  Returns an empty HTTP OK result on success, or CONFLICT (409) if the invitation
+//has already been accepted, revoked, or has expired.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) RevokeInvitation(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	invitationId, err := uuid.Parse(c.Param("invitationid"))
+	if err != nil {
+		return err
+	}
+	if _, err := sh.store.GetInvitation(surveyId, invitationId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Invitation not found")
+		}
+		return err
+	}
+	if err := sh.store.RevokeInvitation(surveyId, invitationId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusConflict, "Invitation is no longer pending")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+const (
+	defaultPageRows = 50
+	maxPageRows     = 500
+)
+
+//Lists the assignments made to the requesting user in a survey, in survey order. Returns a JSON array.
+//This method takes three optional query parameters:
+//
+//status: open, draft, completed, or all (default)
+//
+//r: the number of rows to return (default 50, at most 500)
+//
+//p: the page number to return (default 0)
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles
+func (sh *SurveyHandler) GetMyAssignments(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	status := c.QueryParam("status")
+	switch status {
+	case "all":
+		status = ""
+	case "", "open", "draft", "completed":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+	}
+	rows, page, err := pageParams(c)
+	if err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	assignments, err := sh.store.GetMemberAssignments(claims.Sub, surveyId, status, rows, page)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, assignments)
+}
+
+//Returns the survey structure for one of the requesting user's assignments, whether it is open, a draft, or completed.
+//The result version is returned in the ETag header.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles.  Members may only read their own assignments.
+func (sh *SurveyHandler) GetMyAssignment(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	saId, err := uuid.Parse(c.Param("said"))
+	if err != nil {
+		return err
+	}
+	sa, err := sh.authorizeAssignment(c, surveyId, saId)
+	if err != nil {
+		return err
+	}
+	structure, err := sh.store.GetStructure(sa.SurveyElement_ID, sa.ID)
+	if err != nil {
+		return err
+	}
+	setETag(c, structure.Version)
+	return c.JSON(http.StatusOK, structure)
+}
+
+//Reopens a completed assignment so the user can correct their submission.  The assignment is returned by
+//AssignSurveyElement again until it is resubmitted.  Only allowed while the survey is active.
+//Returns an HTTP OK on success or an HTTP CONFLICT (409) if the survey is closed or the assignment is not complete.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles.  Members may only reopen their own assignments.
+func (sh *SurveyHandler) ReopenMyAssignment(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	saId, err := uuid.Parse(c.Param("said"))
+	if err != nil {
+		return err
+	}
+	if _, err := sh.authorizeAssignment(c, surveyId, saId); err != nil {
+		return err
+	}
+	survey, err := sh.store.GetSurvey(surveyId)
+	if err != nil {
+		return err
+	}
+	if !survey.Active {
+		return echo.NewHTTPError(http.StatusConflict, "Survey is closed")
+	}
+	err = sh.store.ReopenAssignment(saId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusConflict, "Assignment is not complete")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, `{"result":"reopened"}`)
+}
+
+// pageParams reads the optional r (rows) and p (page) query parameters.  Rows are capped at maxPageRows.
+func pageParams(c echo.Context) (int, int, error) {
+	rows, page := defaultPageRows, 0
+	var err error
+	if r := c.QueryParam("r"); r != "" {
+		if rows, err = strconv.Atoi(r); err != nil || rows < 1 {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+		}
+		if rows > maxPageRows {
+			rows = maxPageRows
+		}
+	}
+	if p := c.QueryParam("p"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 0 {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+		}
+	}
+	return rows, page, nil
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		query string
+		rows  int
+		page  int
+		code  int
+	}{
+		{"", defaultPageRows, 0, 0},
+		{"r=10&p=2", 10, 2, 0},
+		{"r=100000", maxPageRows, 0, 0},
+		{"r=0", 0, 0, http.StatusBadRequest},
+		{"r=ten", 0, 0, http.StatusBadRequest},
+		{"p=-1", 0, 0, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		_, c := surveyContext(http.MethodGet, "/?"+test.query, "", "987654", uuid.New())
+		rows, page, err := pageParams(c)
+		assert.Equal(t, test.code, httpStatus(err), test.query)
+		assert.Equal(t, test.rows, rows, test.query)
+		assert.Equal(t, test.page, page, test.query)
+	}
+}
+
+func listMyAssignments(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string, query string) []models.MemberAssignment {
+	rec, c := surveyContext(http.MethodGet, "/?"+query, "", userId, surveyId)
+	assignments := []models.MemberAssignment{}
+	if assert.NoError(t, h.GetMyAssignments(c)) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &assignments))
+	}
+	return assignments
+}
+
+func TestMyAssignmentHistory(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	submitted, _ := assignTestStructure(t, h, surveyId, "987654")
+	_, err := h.store.SaveSurvey(&submitted, "987654", nil, false)
+	assert.NoError(t, err)
+	open, _ := assignTestStructure(t, h, surveyId, "987654")
+
+	assert.Len(t, listMyAssignments(t, h, surveyId, "987654", ""), 2)
+	assert.Len(t, listMyAssignments(t, h, surveyId, "987654", "status=all&r=1"), 1)
+	completed := listMyAssignments(t, h, surveyId, "987654", "status=completed")
+	if assert.Len(t, completed, 1) {
+		assert.Equal(t, submitted.SAID, completed[0].SAID)
+		assert.True(t, completed[0].Completed)
+	}
+	pending := listMyAssignments(t, h, surveyId, "987654", "status=open")
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, open.SAID, pending[0].SAID)
+	}
+	_, c := surveyContext(http.MethodGet, "/?status=closed", "", "987654", surveyId)
+	assert.Equal(t, http.StatusBadRequest, httpStatus(h.GetMyAssignments(c)))
+
+	rec, c := surveyContext(http.MethodGet, "/", "", "987654", surveyId, "said", submitted.SAID.String())
+	if assert.NoError(t, h.GetMyAssignment(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("ETag"))
+	}
+}
+
+func TestReopenMyAssignment(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	s, _ := assignTestStructure(t, h, surveyId, "987654")
+
+	reopen := func(userId string) int {
+		rec, c := surveyContext(http.MethodPut, "/", "", userId, surveyId, "said", s.SAID.String())
+		if err := h.ReopenMyAssignment(c); err != nil {
+			return httpStatus(err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusConflict, reopen("987654"), "open assignments cannot be reopened")
+	_, err := h.store.SaveSurvey(&s, "987654", nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, reopen("987655"))
+	assert.Equal(t, http.StatusOK, reopen("987654"))
+	assert.Len(t, listMyAssignments(t, h, surveyId, "987654", "status=completed"), 0)
+}
+
+func TestResubmitRequiresReopen(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	s, _ := assignTestStructure(t, h, surveyId, "987655")
+	submit := func(userId string) int {
+		payload, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, c := surveyContext(http.MethodPut, "/", string(payload), userId, surveyId)
+		if err := h.SaveSurveyAssignment(c); err != nil {
+			return httpStatus(err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, submit("987655"))
+	s.FoundHt = 3
+	assert.Equal(t, http.StatusConflict, submit("987655"), "a completed assignment must be reopened first")
+
+	_, c := surveyContext(http.MethodPut, "/", "", "987655", surveyId, "said", s.SAID.String())
+	assert.NoError(t, h.ReopenMyAssignment(c))
+	assert.Equal(t, http.StatusOK, submit("987655"))
+	s.FoundHt = 4
+	assert.Equal(t, http.StatusOK, submit("987654"), "owners may correct a completed assignment")
+}
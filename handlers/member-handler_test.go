@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDemoteLastOwner(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	upsert := func(userId string, role string) int {
+		payload := fmt.Sprintf(`{"surveyId":"%s","userId":"%s","role":"%s"}`, surveyId, userId, role)
+		rec, c := surveyContext(http.MethodPost, "/", payload, "987654", surveyId)
+		if err := h.UpsertSurveyMember(c); err != nil {
+			return httpStatus(err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusConflict, upsert("987654", models.RoleViewer))
+	assert.Equal(t, models.RoleOwner, h.store.GetMemberRole(surveyId, "987654"))
+
+	assert.Equal(t, http.StatusCreated, upsert("987655", models.RoleOwner))
+	assert.Equal(t, http.StatusCreated, upsert("987654", models.RoleViewer), "another owner remains")
+
+	rows := []models.MemberImportRow{
+		{Row: 1, User: "987655", Role: models.RoleCoordinator, Action: models.ImportUpdate},
+		{Row: 2, User: "987656", Role: models.RoleSurveyor, Action: models.ImportAdd},
+	}
+	assert.Equal(t, stores.ErrLastOwner, h.store.ImportMembers(surveyId, rows, "987654"))
+	assert.Equal(t, models.RoleOwner, h.store.GetMemberRole(surveyId, "987655"), "a failed import changes nothing")
+	assert.Equal(t, "", h.store.GetMemberRole(surveyId, "987656"))
+}
+
+func TestGroupRemovalKeepsLastOwner(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	group := models.SurveyGroup{SurveyID: surveyId, Group: "nsi-test-owners", Role: models.RoleOwner}
+	assert.Nil(t, h.store.UpsertSurveyGroup(group))
+	assert.Nil(t, h.store.SyncGroupMemberships("987655", []string{group.Group}))
+	assert.Equal(t, models.RoleOwner, h.store.GetMemberRole(surveyId, "987655"))
+	assert.Nil(t, h.store.UpsertSurveyMember(models.SurveyMember{SurveyID: surveyId, UserID: "987654", Role: models.RoleViewer}))
+
+	group.Role = models.RoleSurveyor
+	assert.Equal(t, stores.ErrLastOwner, h.store.UpsertSurveyGroup(group))
+	assert.Nil(t, h.store.SyncGroupMemberships("987655", []string{}))
+	assert.Equal(t, models.RoleOwner, h.store.GetMemberRole(surveyId, "987655"), "a sync keeps the last owner")
+
+	deleteGroup := func() int {
+		rec, c := surveyContext(http.MethodDelete, "/", "", "987655", surveyId, "group", group.Group)
+		if err := h.DeleteSurveyGroup(c); err != nil {
+			return httpStatus(err)
+		}
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusConflict, deleteGroup())
+	assert.Equal(t, models.RoleOwner, h.store.GetMemberRole(surveyId, "987655"))
+
+	assert.Nil(t, h.store.UpsertSurveyMember(models.SurveyMember{SurveyID: surveyId, UserID: "987654", Role: models.RoleOwner}))
+	assert.Equal(t, http.StatusOK, deleteGroup())
+	assert.Equal(t, "", h.store.GetMemberRole(surveyId, "987655"))
+}
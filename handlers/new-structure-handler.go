@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+type reviewRequest struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+//Submits a structure that is missing from the NSI.  The payload carries the coordinates and the same attributes as a
+//survey structure and is validated against the survey data dictionary.  The structure is stored pending owner review under
+//a provisional identifier, which is returned with the new id in an HTTP CREATED (201) result.
+//
+//e.g. {"id":"1111-1111-111111","provisionalId":"NEW-000001"}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles
+func (sh *SurveyHandler) SubmitNewStructure(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	ns := models.NewStructure{}
+	if err := c.Bind(&ns); err != nil {
+		return err
+	}
+	attributes, err := sh.store.GetSurveyDictionary(surveyId)
+	if err != nil {
+		return err
+	}
+	if verrs := models.ValidateNewStructure(ns, attributes); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	ns.SurveyID = surveyId
+	ns.SubmittedBy = claims.Sub
+	id, err := sh.store.InsertNewStructure(ns)
+	if err != nil {
+		log.Printf("Error saving new structure: %s", err)
+		return err
+	}
+	saved, err := sh.store.GetNewStructure(surveyId, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"id":            saved.ID,
+		"provisionalId": saved.ProvisionalID,
+	})
+}
+
+//Lists the surveyor added structures for a survey. Returns a JSON array.  This method takes an optional query parameter:
+//
+//status: pending, approved, or rejected.  All structures are returned when omitted.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetNewStructures(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	status := c.QueryParam("status")
+	if status != "" && !validReviewStatus(status) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+	}
+	structures, err := sh.store.GetNewStructures(surveyId, status)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, structures)
+}
+
+//Records an owner review of a surveyor added structure.  Status must be approved, rejected, or pending to reopen it.
+//Returns an empty HTTP OK result on success.
+//
+//e.g. {"status":"approved","note":"confirmed on imagery"}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_REVIEWER roles
+func (sh *SurveyHandler) ReviewNewStructure(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	id, err := uuid.Parse(c.Param("structureid"))
+	if err != nil {
+		return err
+	}
+	req := reviewRequest{}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if !validReviewStatus(req.Status) {
+		return validationFailed(c, models.ValidationErrors{{Field: "status", Message: req.Status + " is not a valid value"}})
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	err = sh.store.ReviewNewStructure(surveyId, id, req.Status, claims.Sub, req.Note)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Structure not found")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
+
+//Returns a CSV dump of the surveyor added structures for a survey, separate from the corrections to existing fd_ids in the
+//survey report.  Only approved structures are included unless the status query parameter is set to pending, rejected, or all.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetNewStructureReport(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	status := c.QueryParam("status")
+	switch {
+	case status == "":
+		status = models.NewStructureApproved
+	case status == "all":
+		status = ""
+	case !validReviewStatus(status):
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+	}
+	structures, err := sh.store.GetNewStructures(surveyId, status)
+	if err != nil {
+		return err
+	}
+	headers := "provisionalId,status,submittedBy,userName,submittedAt,reviewedBy,x,y,cbfips,occtype,stDamcat,foundHt,numStory,sqft,foundType,rsmeansType,quality,constType,garage,roofStyle\r\n"
+
+	resp := c.Response()
+	resp.Header().Set("Content-type", "text/csv")
+	resp.Header().Set("Content-Disposition", "attachment; filename=new-structures.csv")
+	resp.Header().Set("Pragma", "no-cache")
+	resp.Header().Set("Expires", "0")
+	w := resp.Writer
+	w.Write([]byte(headers))
+	for _, record := range structures {
+		for i, val := range record.String() {
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			if _, err := w.Write([]byte(val)); err != nil {
+				log.Println("error writing new structures to csv:", err)
+				return err
+			}
+		}
+		w.Write([]byte("\r\n"))
+	}
+	return nil
+}
+
+func validReviewStatus(status string) bool {
+	return status == models.NewStructurePending || status == models.NewStructureApproved || status == models.NewStructureRejected
+}
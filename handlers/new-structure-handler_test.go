@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// submitTestStructure submits a new structure to a survey as user 987654 and returns the status and response body
+func submitTestStructure(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, ns models.NewStructure) (int, map[string]interface{}) {
+	payload, err := json.Marshal(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, c := surveyContext(http.MethodPost, "/", string(payload), "987654", surveyId)
+	if err := h.SubmitNewStructure(c); err != nil {
+		return httpStatus(err), nil
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, body
+}
+
+func reviewTestStructure(h *SurveyHandler, surveyId uuid.UUID, id string, status string) int {
+	rec, c := surveyContext(http.MethodPut, "/", `{"status":"`+status+`","note":"checked imagery"}`, "987654", surveyId, "structureid", id)
+	if err := h.ReviewNewStructure(c); err != nil {
+		return httpStatus(err)
+	}
+	return rec.Code
+}
+
+func newTestStructure() models.NewStructure {
+	return models.NewStructure{X: -90.1, Y: 30.2, OccupancyType: "COM1", Damcat: "COM", FoundType: "S"}
+}
+
+func TestSubmitNewStructure(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+
+	code, body := submitTestStructure(t, h, surveyId, newTestStructure())
+	if !assert.Equal(t, http.StatusCreated, code) {
+		return
+	}
+	provisionalId, _ := body["provisionalId"].(string)
+	assert.True(t, strings.HasPrefix(provisionalId, "NEW-"), provisionalId)
+	id, err := uuid.Parse(body["id"].(string))
+	if assert.NoError(t, err) {
+		saved, err := h.store.GetNewStructure(surveyId, id)
+		assert.NoError(t, err)
+		assert.Equal(t, provisionalId, saved.ProvisionalID)
+		assert.Equal(t, models.NewStructurePending, saved.Status)
+		assert.Equal(t, "987654", saved.SubmittedBy)
+	}
+
+	invalid := newTestStructure()
+	invalid.X = 0
+	invalid.Damcat = "RES"
+	code, body = submitTestStructure(t, h, surveyId, invalid)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "attributes are validated against the survey dictionary")
+	assert.Equal(t, "invalid", body["result"])
+	structures, err := h.store.GetNewStructures(surveyId, "")
+	assert.NoError(t, err)
+	assert.Len(t, structures, 1, "an invalid structure is not stored")
+}
+
+func TestReviewNewStructure(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	_, body := submitTestStructure(t, h, surveyId, newTestStructure())
+	id, _ := body["id"].(string)
+	structureId, err := uuid.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transitions := []struct {
+		status string
+		code   int
+		stored string
+	}{
+		{models.NewStructureApproved, http.StatusOK, models.NewStructureApproved},
+		{"complete", http.StatusUnprocessableEntity, models.NewStructureApproved},
+		{models.NewStructurePending, http.StatusOK, models.NewStructurePending},
+		{models.NewStructureRejected, http.StatusOK, models.NewStructureRejected},
+	}
+	for _, tr := range transitions {
+		assert.Equal(t, tr.code, reviewTestStructure(h, surveyId, id, tr.status), tr.status)
+		saved, err := h.store.GetNewStructure(surveyId, structureId)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tr.stored, saved.Status, tr.status)
+		}
+	}
+	saved, _ := h.store.GetNewStructure(surveyId, structureId)
+	if assert.NotNil(t, saved.ReviewedBy) {
+		assert.Equal(t, "987654", *saved.ReviewedBy)
+	}
+	assert.Equal(t, "checked imagery", saved.ReviewNote)
+
+	assert.Equal(t, http.StatusNotFound, reviewTestStructure(h, surveyId, uuid.New().String(), models.NewStructureApproved))
+	other := createTestSurvey(t, h)
+	assert.Equal(t, http.StatusNotFound, reviewTestStructure(h, other, id, models.NewStructureApproved), "structures are reviewed within their survey")
+}
+
+func TestGetNewStructureReport(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	_, approved := submitTestStructure(t, h, surveyId, newTestStructure())
+	_, pending := submitTestStructure(t, h, surveyId, newTestStructure())
+	if code := reviewTestStructure(h, surveyId, approved["id"].(string), models.NewStructureApproved); code != http.StatusOK {
+		t.Fatalf("review failed with %d", code)
+	}
+	approvedId, pendingId := approved["provisionalId"].(string), pending["provisionalId"].(string)
+
+	report := func(target string) (int, string) {
+		rec, c := surveyContext(http.MethodGet, target, "", "987654", surveyId)
+		if err := h.GetNewStructureReport(c); err != nil {
+			return httpStatus(err), ""
+		}
+		return rec.Code, rec.Body.String()
+	}
+	code, csv := report("/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, strings.HasPrefix(csv, "provisionalId,status,"))
+	assert.Contains(t, csv, approvedId)
+	assert.NotContains(t, csv, pendingId, "only approved structures are reported by default")
+	_, csv = report("/?status=all")
+	assert.Contains(t, csv, approvedId)
+	assert.Contains(t, csv, pendingId)
+	code, _ = report("/?status=unknown")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	rec, c := surveyContext(http.MethodGet, "/", "", "987654", surveyId)
+	if assert.NoError(t, h.GetSurveyReport(c)) {
+		assert.NotContains(t, rec.Body.String(), approvedId, "new structures are kept out of the fd_id corrections report")
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+const (
+	defaultCheckout = 10
+	maxCheckout     = 100
+	maxSyncBatch    = 500
+)
+
+//Checks out a block of assignments for offline field work.  New elements are reserved, in the same order
+//AssignSurveyElement would issue them, until the user holds n open assignments.  Returns a JSON array with the
+//full prefill data (including any draft values) for every open assignment the user holds.  Fewer assignments are
+//returned once the survey's assignment limits are reached, and deactivated users only receive the assignments they
+//already hold.
+//
+//n: the number of open assignments to hold (default 10, maximum 100)
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles
+func (sh *SurveyHandler) CheckoutAssignments(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	n := defaultCheckout
+	if q := c.QueryParam("n"); q != "" {
+		if n, err = strconv.Atoi(q); err != nil || n < 1 || n > maxCheckout {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+		}
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	open, err := sh.store.GetMemberAssignments(claims.Sub, surveyId, "open", maxCheckout, 0)
+	if err != nil {
+		return err
+	}
+	if len(open) < n {
+		if _, err := sh.store.AssignNext(claims.Sub, surveyId, n-len(open)); err != nil && err != stores.ErrAssignmentLimit && err != stores.ErrUserInactive {
+			log.Printf("Error checking out assignments: %s", err)
+			return err
+		}
+		open, err = sh.store.GetMemberAssignments(claims.Sub, surveyId, "open", maxCheckout, 0)
+		if err != nil {
+			return err
+		}
+	}
+	structures := make([]models.SurveyStructure, len(open))
+	for i, a := range open {
+		structures[i], err = sh.store.GetStructure(a.SEID, a.SAID)
+		if err != nil {
+			return err
+		}
+	}
+	return c.JSON(http.StatusOK, structures)
+}
+
+//Synchronizes a batch of results collected offline.  The payload is a JSON array of survey structures; items with
+//"draft":true are saved as drafts and all others are submitted.  Every item is processed independently and the response
+//is a JSON array reporting the status of each: submitted, draft, invalid (with field errors), conflict (the assignment was
+//already submitted, now belongs to another user, or its result changed since the version the item carries), not_found,
+//or error.  Each item's version must be the version it was checked out at (0 when there was no saved result).
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles
+func (sh *SurveyHandler) SyncAssignments(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	items := []models.SurveyStructure{}
+	if err := c.Bind(&items); err != nil {
+		return err
+	}
+	if len(items) > maxSyncBatch {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Sync batches are limited to "+strconv.Itoa(maxSyncBatch)+" results")
+	}
+	attributes, err := sh.store.GetSurveyDictionary(surveyId)
+	if err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	manager := isAdmin(claims) || sh.store.IsOwner(surveyId, claims.Sub)
+	results := make([]models.SyncResult, len(items))
+	for i := range items {
+		results[i] = sh.syncItem(surveyId, claims.Sub, manager, &items[i], attributes)
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+func (sh *SurveyHandler) syncItem(surveyId uuid.UUID, userId string, manager bool, s *models.SurveyStructure, attributes []models.Attribute) models.SyncResult {
+	result := models.SyncResult{SAID: s.SAID}
+	sa, err := sh.store.GetSurveyAssignment(surveyId, s.SAID)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			result.Status = models.SyncNotFound
+			return result
+		}
+		log.Printf("Error syncing assignment %s: %s", s.SAID, err)
+		result.Status = models.SyncError
+		return result
+	}
+	switch {
+	case sa.Assigned != userId && !manager:
+		result.Status = models.SyncConflict
+		result.Message = "Assignment belongs to another user"
+		return result
+	case sa.Completed:
+		result.Status = models.SyncConflict
+		result.Message = "Assignment has already been submitted"
+		return result
+	}
+	expectedVersion := s.Version
+	if s.Draft {
+		result.Status = models.SyncDraft
+		if result.Errors = models.ValidateDraft(*s, attributes); len(result.Errors) == 0 {
+			result.Version, err = sh.store.SaveDraft(s, userId, &expectedVersion)
+		}
+	} else {
+		result.Status = models.SyncSubmitted
+		if result.Errors = models.ValidateStructure(*s, attributes); len(result.Errors) == 0 {
+			result.Version, err = sh.store.SaveSurvey(s, userId, &expectedVersion, false)
+		}
+	}
+	if len(result.Errors) > 0 {
+		result.Status = models.SyncInvalid
+	} else if err == stores.ErrVersionConflict {
+		result.Status = models.SyncConflict
+		result.Message = "Result has been modified since it was checked out"
+	} else if err == stores.ErrAssignmentSubmitted {
+		result.Status = models.SyncConflict
+		result.Message = err.Error()
+	} else if err != nil {
+		log.Printf("Error syncing assignment %s: %s", s.SAID, err)
+		result.Status = models.SyncError
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func checkoutTest(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string, query string) (int, []models.SurveyStructure) {
+	rec, c := surveyContext(http.MethodPost, "/?"+query, "", userId, surveyId)
+	structures := []models.SurveyStructure{}
+	if err := h.CheckoutAssignments(c); err != nil {
+		return httpStatus(err), structures
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &structures))
+	return rec.Code, structures
+}
+
+func syncTest(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string, items []models.SurveyStructure) []models.SyncResult {
+	payload, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, c := surveyContext(http.MethodPost, "/", string(payload), userId, surveyId)
+	results := []models.SyncResult{}
+	if assert.NoError(t, h.SyncAssignments(c)) {
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	}
+	return results
+}
+
+func TestCheckoutAssignments(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	code, first := checkoutTest(t, h, surveyId, "987654", "n=3")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, first, 3)
+
+	code, again := checkoutTest(t, h, surveyId, "987654", "n=3")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, again, 3, "a checkout tops up to n open assignments") {
+		for i := range first {
+			assert.Equal(t, first[i].SAID, again[i].SAID)
+		}
+	}
+	code, more := checkoutTest(t, h, surveyId, "987654", "n=5")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, more, 5)
+
+	for _, query := range []string{"n=0", "n=101", "n=all"} {
+		code, _ = checkoutTest(t, h, surveyId, "987654", query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestSyncAssignments(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	_, items := checkoutTest(t, h, surveyId, "987654", "n=4")
+	if !assert.Len(t, items, 4) {
+		return
+	}
+	_, others := checkoutTest(t, h, surveyId, "987655", "n=1")
+	if !assert.Len(t, others, 1) {
+		return
+	}
+	items[0].FoundHt = 3
+	items[1].FoundHt = 4
+	items[1].Draft = true
+	items[2].FoundHt = 999
+	stale := items[3]
+	_, err := h.store.SaveDraft(&items[3], "987654", nil)
+	assert.NoError(t, err)
+	missing := items[0]
+	missing.SAID = uuid.New()
+	results := syncTest(t, h, surveyId, "987654", []models.SurveyStructure{items[0], items[1], items[2], stale, others[0], missing})
+	if !assert.Len(t, results, 6) {
+		return
+	}
+	assert.Equal(t, models.SyncSubmitted, results[0].Status)
+	assert.Equal(t, models.SyncDraft, results[1].Status)
+	assert.Equal(t, models.SyncInvalid, results[2].Status)
+	assert.NotEmpty(t, results[2].Errors)
+	assert.Equal(t, models.SyncConflict, results[3].Status, "the result changed since checkout")
+	assert.Equal(t, models.SyncConflict, results[4].Status, "the assignment belongs to another user")
+	assert.Equal(t, models.SyncNotFound, results[5].Status)
+
+	results = syncTest(t, h, surveyId, "987654", items[:1])
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, models.SyncConflict, results[0].Status, "the assignment was already submitted")
+	}
+	drafts, err := h.store.GetMemberAssignments("987654", surveyId, "draft", 10, 0)
+	if assert.NoError(t, err) && assert.Len(t, drafts, 1) {
+		assert.Equal(t, items[1].SAID, drafts[0].SAID)
+	}
+}
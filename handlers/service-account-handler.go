@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/usace/microauth"
+)
+
+//Creates a service account for automation such as ETL jobs.  Service accounts authenticate with API keys rather than
+//identity provider tokens.  Returns the new account as JSON with an HTTP CREATED (201) result on success, or CONFLICT
+//(409) if the name is taken.
+//
+//e.g. {"name":"nsi-etl","description":"Nightly element load"}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) CreateServiceAccount(c echo.Context) error {
+	account := models.ServiceAccount{}
+	if err := c.Bind(&account); err != nil {
+		return err
+	}
+	if verrs := account.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	account.CreatedBy = claims.Sub
+	id, err := sh.store.InsertServiceAccount(account)
+	if err != nil {
+		if err == stores.ErrServiceAccountExists {
+			return echo.NewHTTPError(http.StatusConflict, "A service account named "+account.Name+" already exists")
+		}
+		return err
+	}
+	account, err = sh.store.GetServiceAccount(id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, account)
+}
+
+//Lists the service accounts as a JSON array ordered by name, with the number of keys that are neither revoked nor
+//expired.
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) GetServiceAccounts(c echo.Context) error {
+	accounts, err := sh.store.GetServiceAccounts()
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, accounts)
+}
+
+//Enables or disables a service account.  The keys of a disabled account are rejected until it is enabled again.
+//Returns the account as JSON on success.
+//
+//e.g. {"active":false}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) SetServiceAccountActive(c echo.Context) error {
+	accountId, err := uuid.Parse(c.Param("accountid"))
+	if err != nil {
+		return err
+	}
+	body := userActive{}
+	if err := c.Bind(&body); err != nil {
+		return err
+	}
+	if err := sh.store.SetServiceAccountActive(accountId, body.Active); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return err
+	}
+	account, err := sh.store.GetServiceAccount(accountId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
+//Issues an API key for a service account, scoped to a list of surveys and permissions.  Keys expire after expiresInDays
+//(at most 365), or never when it is omitted.  Callers present the key in the X-API-Key header in place of a bearer
+//token.  Permissions open the following routes on the listed surveys:
+//
+//elements:read: GET /survey/{surveyid}/elements
+//elements:write: POST /survey/{surveyid}/elements
+//assignments:write: POST /survey/{surveyid}/assignments
+//report:read: GET /survey/{surveyid}/report, /survey/{surveyid}/report/geojson, and /survey/{surveyid}/structures/report
+//
+//e.g. {"name":"nightly load","permissions":["elements:write","report:read"],"surveys":["{surveyid}"],"expiresInDays":90}
+//
+//Returns the key as JSON with an HTTP CREATED (201) result on success.  The key field holds the only copy of the key;
+//only its prefix and a hash are stored.
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) CreateAPIKey(c echo.Context) error {
+	accountId, err := uuid.Parse(c.Param("accountid"))
+	if err != nil {
+		return err
+	}
+	key := models.APIKey{}
+	if err := c.Bind(&key); err != nil {
+		return err
+	}
+	if verrs := key.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	if _, err := sh.store.GetServiceAccount(accountId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Service account not found")
+		}
+		return err
+	}
+	for _, surveyId := range key.Surveys {
+		if _, err := sh.store.GetSurvey(surveyId); err != nil {
+			if err.Error() == stores.NoResults {
+				return validationFailed(c, models.ValidationErrors{{Field: "surveys", Message: surveyId.String() + " is not a survey"}})
+			}
+			return err
+		}
+	}
+	secret, prefix, err := models.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	key.AccountID = accountId
+	key.Prefix = prefix
+	key.CreatedBy = claims.Sub
+	id, err := sh.store.InsertAPIKey(key, models.HashAPIKey(secret))
+	if err != nil {
+		log.Printf("Error issuing API key: %s", err)
+		return err
+	}
+	key, err = sh.store.GetAPIKey(accountId, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, models.IssuedAPIKey{APIKey: key, Key: secret})
+}
+
+//Lists the API keys of a service account as a JSON array, newest first, with the time each key was last used.  Keys
+//are identified by their prefix; the keys themselves are never returned.
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) GetAPIKeys(c echo.Context) error {
+	accountId, err := uuid.Parse(c.Param("accountid"))
+	if err != nil {
+		return err
+	}
+	keys, err := sh.store.GetAPIKeys(accountId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+//Revokes an API key.  Revoked keys are rejected immediately and cannot be restored.  Returns an empty HTTP OK result
+//on success, or CONFLICT (409) if the key has already been revoked.
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) RevokeAPIKey(c echo.Context) error {
+	accountId, err := uuid.Parse(c.Param("accountid"))
+	if err != nil {
+		return err
+	}
+	keyId, err := uuid.Parse(c.Param("keyid"))
+	if err != nil {
+		return err
+	}
+	if _, err := sh.store.GetAPIKey(accountId, keyId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+		}
+		return err
+	}
+	if err := sh.store.RevokeAPIKey(accountId, keyId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusConflict, "API key has already been revoked")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
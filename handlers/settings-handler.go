@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+//Returns the assignment settings for a survey.  Surveys without saved settings use the survey_order strategy
+//with no assignment limits.
+//
+//e.g. {"surveyId":"...","assignmentStrategy":"nearest","maxAssignmentsPerMember":0,"dailyCap":0,"controlRate":0}
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) GetSurveySettings(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	settings, err := sh.store.GetSurveySettings(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, settings)
+}
+
+//Updates the assignment settings for a survey.  Fields omitted from the payload keep their current values.
+//The assignment strategy controls the order new elements are issued in:
+//  survey_order:  ascending survey order (the default)
+//  nearest:       the unassigned element closest to the user's last completed structure
+//  random:        a random unassigned element
+//  block_cluster: the remaining elements in the census block of the user's last completed structure before moving on
+//maxAssignmentsPerMember and dailyCap limit the number of elements issued to each member in total and per server day.
+//controlRate issues each member a control element after every controlRate regular elements; when it is 0 control
+//elements are issued once the survey (survey_order strategy) or the member (other strategies) has progressed past
+//their survey order.  Zero values are unlimited.
+//Returns an empty HTTP OK result on success.
+//
+//e.g. {"assignmentStrategy":"nearest","maxAssignmentsPerMember":500,"dailyCap":100,"controlRate":20}
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) UpdateSurveySettings(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	settings, err := sh.store.GetSurveySettings(surveyId)
+	if err != nil {
+		return err
+	}
+	if err := c.Bind(&settings); err != nil {
+		return err
+	}
+	settings.SurveyID = surveyId
+	verrs := settings.Validate()
+	if !stores.ValidAssignmentStrategy(settings.AssignmentStrategy) {
+		verrs = append(verrs, models.FieldError{
+			Field:   "assignmentStrategy",
+			Message: fmt.Sprintf("%s is not a valid assignment strategy", settings.AssignmentStrategy),
+		})
+	}
+	if len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	err = sh.store.UpdateSurveySettings(settings)
+	if err != nil {
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
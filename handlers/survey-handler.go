@@ -3,14 +3,16 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/blobs"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx"
 	"github.com/labstack/echo/v4"
 	"github.com/usace/microauth"
 )
@@ -20,12 +22,16 @@ var defaultUuid uuid.UUID
 const version = "2.0.1 Development"
 
 type SurveyHandler struct {
-	store *stores.SurveyStore
+	store             *stores.SurveyStore
+	blobs             blobs.BlobStore
+	maxAttachmentSize int64
 }
 
-func CreateSurveyHandler(ss *stores.SurveyStore) *SurveyHandler {
+func CreateSurveyHandler(ss *stores.SurveyStore, bs blobs.BlobStore, maxAttachmentSize int64) *SurveyHandler {
 	sh := SurveyHandler{
-		store: ss,
+		store:             ss,
+		blobs:             bs,
+		maxAttachmentSize: maxAttachmentSize,
 	}
 	return &sh
 }
@@ -99,9 +105,9 @@ func (sh *SurveyHandler) UpdateSurvey(c echo.Context) error {
 	return c.String(http.StatusOK, "")
 }
 
-//Gets an array of survey members for a given survey. Returns a JSON array.
+//Gets an array of survey members for a given survey, including each member's role. Returns a JSON array.
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
 func (sh *SurveyHandler) GetSurveyMembers(c echo.Context) error {
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if err != nil {
@@ -116,8 +122,13 @@ func (sh *SurveyHandler) GetSurveyMembers(c echo.Context) error {
 }
 
 //Updates/Inserts a survey member record. Returns an empty HTTP CREATED (201) result on success.
+//The role is one of owner, coordinator, reviewer, surveyor, or viewer.  Records without a role
+//use isOwner to choose between owner and surveyor.  Only owners may grant or change the owner role, and demoting
+//the last owner fails with an HTTP CONFLICT (409) result.
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//e.g. {"surveyId":"...","userId":"987654","role":"reviewer"}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
 func (sh *SurveyHandler) UpsertSurveyMember(c echo.Context) error {
 	var surveyMember = models.SurveyMember{}
 	if err := c.Bind(&surveyMember); err != nil {
@@ -126,50 +137,203 @@ func (sh *SurveyHandler) UpsertSurveyMember(c echo.Context) error {
 	if !validateUrl(surveyMember.SurveyID, c) {
 		return errors.New("Invalid Request")
 	}
+	surveyMember.Role = surveyMember.MemberRole()
+	if !models.ValidRole(surveyMember.Role) {
+		return validationFailed(c, models.ValidationErrors{{Field: "role", Message: fmt.Sprintf("%s is not a valid role", surveyMember.Role)}})
+	}
+	if surveyMember.Role == models.RoleOwner || sh.store.IsOwner(surveyMember.SurveyID, surveyMember.UserID) {
+		claims := c.Get("NSIUSER").(microauth.JwtClaim)
+		if !isAdmin(claims) && !sh.store.IsOwner(surveyMember.SurveyID, claims.Sub) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only owners may grant or change the owner role")
+		}
+	}
 	err := sh.store.UpsertSurveyMember(surveyMember)
 	if err != nil {
+		if err == stores.ErrLastOwner {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		log.Printf("Error adding survey member: %s", err)
 		return err
 	}
 	return c.String(http.StatusCreated, "")
 }
 
-//Removes a survey member record. Returns an empty HTTP OK result on success.
+//Removes a survey membership by its id.  Settles the member's open assignments as RemoveMemberFromSurvey does.
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//PRIVATE API restricted to the ADMIN role
 func (sh *SurveyHandler) RemoveSurveyMember(c echo.Context) error {
 	memberId, err := uuid.Parse(c.Param("memberid"))
 	if err != nil {
 		return err
 	}
-	err = sh.store.RemoveSurveyMember(memberId)
+	member, err := sh.store.GetSurveyMember(memberId)
 	if err != nil {
-		log.Printf("Error removing survey member: %s", err)
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Survey member not found")
+		}
 		return err
 	}
-	return c.String(http.StatusOK, "")
+	return sh.removeMember(c, member.SurveyID, member.UserID)
 }
 
-//Removes a survey member record from a specific survey. Returns an empty HTTP OK result on success.
+//Removes a user from a specific survey.  If the member holds open assignments the assignments query parameter
+//must say what happens to them:
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//release: the assignments, their draft results, and their attachments are deleted and the elements can be issued again
+//reassign: the assignments move to the member named by reassignTo; controls that member already holds are released
+//keep: the assignments stay with the user
+//
+//e.g. DELETE /survey/{surveyid}/member/{userid}?assignments=reassign&reassignTo=987654
+//
+//Returns the number of assignments released, reassigned, and kept.  The last owner of a survey cannot be
+//removed, and only owners may remove another owner.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
 func (sh *SurveyHandler) RemoveMemberFromSurvey(c echo.Context) error {
-	memberId := c.Param("memberid")
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if err != nil {
 		return err
 	}
-	err = sh.store.RemoveMemberFromSurvey(memberId, surveyId)
+	return sh.removeMember(c, surveyId, c.Param("memberid"))
+}
+
+func (sh *SurveyHandler) removeMember(c echo.Context, surveyId uuid.UUID, userId string) error {
+	removal := models.MemberRemoval{
+		Assignments: c.QueryParam("assignments"),
+		ReassignTo:  c.QueryParam("reassignTo"),
+	}
+	if sh.store.IsOwner(surveyId, userId) {
+		claims := c.Get("NSIUSER").(microauth.JwtClaim)
+		if !isAdmin(claims) && !sh.store.IsOwner(surveyId, claims.Sub) {
+			return echo.NewHTTPError(http.StatusForbidden, "Only owners may remove an owner")
+		}
+	}
+	open, err := sh.store.OpenAssignmentCount(surveyId, userId)
 	if err != nil {
+		return err
+	}
+	if verrs := removal.Validate(userId, open); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	if removal.Assignments == models.ReassignAssignments && !sh.store.IsMember(surveyId, removal.ReassignTo) {
+		return validationFailed(c, models.ValidationErrors{{Field: "reassignTo", Message: "must be a member of the survey"}})
+	}
+	if removal.Assignments == models.ReassignAssignments {
+		if active, err := sh.store.IsActiveUser(removal.ReassignTo); err != nil || !active {
+			return validationFailed(c, models.ValidationErrors{{Field: "reassignTo", Message: "must be an active user"}})
+		}
+	}
+	result, err := sh.store.RemoveSurveyMember(surveyId, userId, removal)
+	if err != nil {
+		switch {
+		case err.Error() == stores.NoResults:
+			return echo.NewHTTPError(http.StatusNotFound, "Survey member not found")
+		case err == stores.ErrLastOwner:
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		log.Printf("Error removing survey member: %s", err)
 		return err
 	}
-	return c.String(http.StatusOK, "")
+	for _, a := range result.Attachments {
+		sh.deleteAttachmentBlobs(a)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+//Adds or updates survey members in bulk from a CSV file uploaded as the multipart form field file.  Each row holds
+//a user id or email and an optional role (default surveyor); a header row is optional:
+//
+//  user,role
+//  987654,coordinator
+//  crew.lead@example.com,surveyor
+//
+//User ids must belong to existing users and are added to the survey or have their role updated.  Emails are
+//invited as CreateInvitation does.  Every row is validated before any change is made and failures are returned as
+//an HTTP UNPROCESSABLE ENTITY (422) result listing each row's errors.  The batch is applied in a single transaction
+//and fails with an HTTP CONFLICT (409) result if it would leave the survey without an owner.
+//
+//dryRun: when true, the file is validated and the planned action for each row is returned without applying it
+//
+//e.g. {"result":"imported","added":38,"updated":1,"invited":2,"rows":[{"row":2,"user":"987654","role":"coordinator","action":"add"},...]}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) ImportSurveyMembers(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	dryRun := c.QueryParam("dryRun") == "true"
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "A CSV file is required")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, verrs := models.ParseMemberImport(f)
+	if len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	userIds := []string{}
+	for _, row := range rows {
+		userIds = append(userIds, row.User)
+	}
+	known, err := sh.store.KnownUsers(userIds)
+	if err != nil {
+		return err
+	}
+	members, err := sh.store.GetSurveyMembers(surveyId)
+	if err != nil {
+		return err
+	}
+	roles := map[string]string{}
+	for _, m := range *members {
+		roles[m.UserID] = m.Role
+	}
+	invitations, err := sh.store.GetInvitations(surveyId, models.InvitationPending)
+	if err != nil {
+		return err
+	}
+	invited := map[string]bool{}
+	for _, i := range invitations {
+		if i.Email != "" {
+			invited[strings.ToLower(i.Email)] = true
+		}
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	manageOwners := isAdmin(claims) || sh.store.IsOwner(surveyId, claims.Sub)
+	if verrs := models.PlanMemberImport(rows, known, roles, invited, manageOwners); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	result := models.MemberImportResult{Result: "valid", Rows: rows}
+	for _, row := range rows {
+		switch row.Action {
+		case models.ImportAdd:
+			result.Added++
+		case models.ImportUpdate:
+			result.Updated++
+		case models.ImportInvite:
+			result.Invited++
+		}
+	}
+	if !dryRun {
+		if err := sh.store.ImportMembers(surveyId, rows, claims.Sub); err != nil {
+			if err == stores.ErrLastOwner {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			log.Printf("Error importing survey members: %s", err)
+			return err
+		}
+		result.Result = "imported"
+	}
+	return c.JSON(http.StatusOK, result)
 }
 
 //Gets an array of survey elements for a given survey. Returns a JSON array.
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
 func (sh *SurveyHandler) GetSurveyElements(c echo.Context) error {
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if err != nil {
@@ -206,7 +370,7 @@ func (sh *SurveyHandler) InsertSurveyElements(c echo.Context) error {
 //method for manually making assignments to users.  Typically assignments should be made using the AssignSurveyElement method
 //but this allows for admins to override the normal assignment algorithm. Returns an empty HTTP CREATED (201) result on success.
 //
-//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
 func (sh *SurveyHandler) AddAssignments(c echo.Context) error {
 	var assignments = []models.SurveyAssignment{}
 	if err := c.Bind(&assignments); err != nil {
@@ -221,13 +385,19 @@ func (sh *SurveyHandler) AddAssignments(c echo.Context) error {
 }
 
 //Assigns a survey element to a survey member.  It works in the following manner:
-//If a user has an existing assignment that has not been saved, then that survey is returned. If the user does not have an existing assignment,
-//then surveys will be assigned in ascending order based on the survey order field.  Each survey will be
-//assigned to a single user with the exception of control surveys.  Control surveys will be assigned to all users.
+//If a user has an existing assignment that has not been submitted, then that survey is returned along with any
+//draft values saved for it (flagged with "draft":true). If the user does not have an existing assignment,
+//then a new survey is assigned in the order chosen by the survey's assignment strategy (see GetSurveySettings).
+//Each survey will be assigned to a single user with the exception of control surveys.  Control surveys will be
+//assigned to all users once the survey has progressed past their survey order.
+//Members bound to territories (see CreateTerritory) are only assigned regular surveys within their territories.
 //When there are no more surveys to assign (all surveys are completed and the user has completed their control surveys),
-//then the function will return an empty survey (e.g. id values of 0).
+//then the function will return {"result":"completed"}.
+//When the user has reached the survey's assignment limits (see UpdateSurveySettings), the function will return
+//{"result":"limit_reached"}.  Deactivated users may finish their open assignments but are refused new ones with an
+//HTTP FORBIDDEN (403) result.  Archived surveys return an HTTP CONFLICT (409) result.
 //
-//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles
 func (sh *SurveyHandler) AssignSurveyElement(c echo.Context) error {
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if err != nil {
@@ -235,76 +405,142 @@ func (sh *SurveyHandler) AssignSurveyElement(c echo.Context) error {
 	}
 	claims := c.Get("NSIUSER").(microauth.JwtClaim)
 	userId := claims.Sub
-	assignmentInfo, err := sh.store.GetAssignmentInfo(userId, surveyId)
+	open, err := sh.store.GetMemberAssignments(userId, surveyId, "open", 1, 0)
 	if err != nil {
 		return err
 	}
-
-	// No potential assignment exists for survey
-	if assignmentInfo.SAID == nil &&
-		assignmentInfo.SEID == nil &&
-		assignmentInfo.Completed == nil &&
-		assignmentInfo.SurveyOrder == nil &&
-		assignmentInfo.NextSurveyOrder == nil &&
-		assignmentInfo.NextSurveySEID == nil &&
-		assignmentInfo.NextControlOrder == nil &&
-		assignmentInfo.NextControlSEID == nil {
-		return c.String(200, `{"result":"completed"}`)
-	}
-
-	var structure models.SurveyStructure
-	var nextSurvey *uuid.UUID
-	if assignmentInfo.Completed == nil {
-		//the user does not have any uncompleted surveys assigned.  get a new one.
-		nextSurvey = assignmentInfo.NextSurveySEID
-		// control order is less than survey order
-		if assignmentInfo.NextControlOrder != nil && assignmentInfo.NextSurveyOrder != nil &&
-			*assignmentInfo.NextControlOrder < *assignmentInfo.NextSurveyOrder {
-			nextSurvey = assignmentInfo.NextControlSEID
+	var seId, saId uuid.UUID
+	if len(open) > 0 {
+		// if current assignment is incomplete, return it
+		seId, saId = open[0].SEID, open[0].SAID
+	} else {
+		assigned, err := sh.store.AssignNext(userId, surveyId, 1)
+		if err == stores.ErrAssignmentLimit {
+			return c.String(200, `{"result":"limit_reached"}`)
 		}
-		if nextSurvey != nil {
-			saId, err := sh.store.AssignSurvey(userId, *nextSurvey)
-			fmt.Println(nextSurvey)
-			if err != nil {
-				log.Printf("Error assigning Survey: %s", err)
-				pgerr := err.(pgx.PgError)
-				// postgres 23503 error code is foreign key violation
-				if pgerr.Code == "23503" && pgerr.TableName == "survey_assignment" {
-					return c.String(200, `{"result":"completed"}`) //this should only occur when we are out of surveys
-				}
-				return err
-			}
-			structure, err = sh.store.GetStructure(*nextSurvey, saId)
-			if err != nil {
-				return err
-			}
+		if err == stores.ErrUserInactive {
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
 		}
-	} else { // if current assignment is incomplete, return it
-		structure, err = sh.store.GetStructure(*assignmentInfo.SEID, *assignmentInfo.SAID)
 		if err != nil {
+			log.Printf("Error assigning Survey: %s", err)
 			return err
 		}
+		if len(assigned) == 0 {
+			return c.String(200, `{"result":"completed"}`)
+		}
+		seId, saId = assigned[0].SurveyElement_ID, assigned[0].ID
+	}
+	structure, err := sh.store.GetStructure(seId, saId)
+	if err != nil {
+		return err
 	}
+	setETag(c, structure.Version)
 	return c.JSON(http.StatusOK, structure)
 
 }
 
-//Saves the survey assignment and returns an HTTP OK on success
+//Submits the survey assignment, completing it, and returns an HTTP OK on success with the new result version in the ETag header.
+//Structures failing validation against the survey data dictionary are rejected with an HTTP UNPROCESSABLE ENTITY (422)
+//result listing each field error
 //
-//PUBLIC API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_MEMBER roles
-
+//e.g. {"result":"invalid","errors":[{"field":"damcat","message":"COM is not consistent with occupancy type RES1 (expected RES)"}]}
+//
+//Clients guard against overwriting a concurrent edit by sending the ETag they loaded in an If-Match header.  The header
+//is opt-in: requests without it (or with If-Match: *) replace the current result unconditionally.  If the result has
+//changed since the version in the header, an HTTP CONFLICT (409) result returns both the current and submitted versions:
+//
+//e.g. {"result":"conflict","current":{...},"submitted":{...}}
+//
+//Retries may carry an Idempotency-Key header to receive the original response rather than resubmitting.
+//
+//A completed assignment returns an HTTP CONFLICT (409) until its assignee reopens it; admins and survey owners may
+//correct it directly.
+//
+//PUBLIC API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles.  Members may only submit their own assignments.
 func (sh *SurveyHandler) SaveSurveyAssignment(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
 	s := models.SurveyStructure{}
 	if err := c.Bind(&s); err != nil {
 		return err
 	}
-	err := sh.store.SaveSurvey(&s)
+	sa, err := sh.authorizeAssignment(c, surveyId, s.SAID)
+	if err != nil {
+		return err
+	}
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	attributes, err := sh.store.GetSurveyDictionary(surveyId)
 	if err != nil {
 		return err
 	}
+	if verrs := models.ValidateStructure(s, attributes); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	resubmit := isAdmin(claims) || sh.store.IsOwner(surveyId, claims.Sub)
+	version, err := sh.store.SaveSurvey(&s, claims.Sub, expectedVersion, resubmit)
+	if err != nil {
+		switch err {
+		case stores.ErrVersionConflict:
+			return sh.versionConflict(c, sa, s)
+		case stores.ErrAssignmentSubmitted:
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return err
+	}
+	setETag(c, version)
 	return c.String(http.StatusOK, `{"result":"success"}`)
 }
 
+//Saves partial work on a survey assignment without completing it.  Draft values are returned by AssignSurveyElement
+//when the user reloads the assignment and are only checked against the column limits of the survey_result table.
+//Returns an HTTP OK on success or an HTTP CONFLICT (409) if the assignment has already been submitted.  Versioning
+//with ETag/If-Match and Idempotency-Key retries work as in SaveSurveyAssignment.
+//
+//PUBLIC API restricted to the ADMIN, SURVEY_OWNER, and SURVEY_SURVEYOR roles.  Members may only save their own assignments.
+func (sh *SurveyHandler) SaveSurveyAssignmentDraft(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	s := models.SurveyStructure{}
+	if err := c.Bind(&s); err != nil {
+		return err
+	}
+	sa, err := sh.authorizeAssignment(c, surveyId, s.SAID)
+	if err != nil {
+		return err
+	}
+	if sa.Completed {
+		return echo.NewHTTPError(http.StatusConflict, "Assignment has already been submitted")
+	}
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+	if verrs := models.ValidateDraft(s, models.StructureAttributes); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	version, err := sh.store.SaveDraft(&s, claims.Sub, expectedVersion)
+	if err != nil {
+		switch err {
+		case stores.ErrVersionConflict:
+			return sh.versionConflict(c, sa, s)
+		case stores.ErrAssignmentSubmitted:
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return err
+	}
+	setETag(c, version)
+	return c.String(http.StatusOK, `{"result":"draft"}`)
+}
+
 //Search the user list.  This method takes three query parameters:
 //
 //q: the query term that will match against the user name
@@ -325,7 +561,7 @@ func (sh *SurveyHandler) SearchUsers(c echo.Context) error {
 	if q == "" || errRow != nil || errPage != nil {
 		return errors.New("Invalid Query Parameters")
 	}
-	users, err := sh.store.DS.Select("select * from users where user_name ilike $1 limit $2 offset $3").
+	users, err := sh.store.DS.Select("select user_id,user_name from users where user_name ilike $1 limit $2 offset $3").
 		Params("%"+q+"%", rows, rows*page).
 		FetchJSON()
 	if err != nil {
@@ -358,30 +594,50 @@ func (sh *SurveyHandler) ValidSurveyName(c echo.Context) error {
 	return c.JSONBlob(http.StatusOK, []byte(`{"result":`+strconv.FormatBool(!invalid)+`}`))
 }
 
-//Returns a CSV dump of the survey results for a given survey
+//Returns a CSV dump of the survey results for a given survey.  The attachments column holds a
+//semicolon separated list of attachment links for each result.
 //
-//PRIVATE API restructed to the ADMIN or SURVEY_OWNER role
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
 func (sh *SurveyHandler) GetSurveyReport(c echo.Context) error {
 	surveyId, err := uuid.Parse(c.Param("surveyid"))
 	if err != nil {
 		return err
 	}
-
-	s, err := sh.store.GetReport(surveyId)
+	s, attachments, err := sh.loadSurveyReport(surveyId)
 	if err != nil {
 		return err
 	}
-	headers := "srId, userId, userName,completed,isControl,saId,fdId,x,y,invalidStructure,noStreetView,cbfips,occtype,stDamcat,foundHt,numStory,sqft,foundType,rsmeansType,quality,constType,garage,roofStyle\r\n"
-
 	resp := c.Response()
 	resp.Header().Set("Content-type", "text/csv")
 	resp.Header().Set("Content-Disposition", "attachment; filename=surveys.csv")
 	resp.Header().Set("Pragma", "no-cache")
 	resp.Header().Set("Expires", "0")
-	w := resp.Writer
+	return writeSurveyReport(resp.Writer, s, attachments)
+}
+
+// loadSurveyReport reads the survey results for a survey with the attachment links of each result
+func (sh *SurveyHandler) loadSurveyReport(surveyId uuid.UUID) ([]models.SurveyResult, map[uuid.UUID][]models.AttachmentLink, error) {
+	s, err := sh.store.GetReport(surveyId)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachments, err := sh.surveyAttachmentLinks(surveyId)
+	return s, attachments, err
+}
+
+// writeSurveyReport writes survey results as the CSV survey report
+func writeSurveyReport(w io.Writer, s []models.SurveyResult, attachments map[uuid.UUID][]models.AttachmentLink) error {
+	headers := "srId, userId, userName,completed,isControl,saId,fdId,x,y,invalidStructure,noStreetView,cbfips,occtype,stDamcat,foundHt,numStory,sqft,foundType,rsmeansType,quality,constType,garage,roofStyle,attachments\r\n"
+
 	w.Write([]byte(headers))
 	for _, record := range s {
-		vals := record.String()
+		links := []string{}
+		for _, link := range attachments[record.SAID] {
+			if link.Url != "" {
+				links = append(links, link.Url)
+			}
+		}
+		vals := append(record.String(), fmt.Sprintf(`"%s"`, strings.Join(links, ";")))
 		for i, val := range vals {
 			if i > 0 {
 				w.Write([]byte(","))
@@ -393,7 +649,38 @@ func (sh *SurveyHandler) GetSurveyReport(c echo.Context) error {
 		}
 		w.Write([]byte("\r\n"))
 	}
-	return err
+	return nil
+}
+
+//Returns the survey results for a given survey as a GeoJSON FeatureCollection of points.  Each feature
+//carries the survey result attributes and its attachment links as properties.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetSurveyReportGeoJSON(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	results, attachments, err := sh.loadSurveyReport(surveyId)
+	if err != nil {
+		return err
+	}
+	fc := models.GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]models.GeoJSONFeature, len(results)),
+	}
+	for i, r := range results {
+		links := attachments[r.SAID]
+		if links == nil {
+			links = []models.AttachmentLink{}
+		}
+		fc.Features[i] = models.NewPointFeature(r.X, r.Y, struct {
+			models.SurveyResult
+			Attachments []models.AttachmentLink `json:"attachments"`
+		}{r, links})
+	}
+	c.Response().Header().Set("Content-Disposition", "attachment; filename=surveys.geojson")
+	return c.JSON(http.StatusOK, fc)
 }
 
 func validateElements(elements *[]models.SurveyElement) (uuid.UUID, bool) {
@@ -410,6 +697,65 @@ func validateElements(elements *[]models.SurveyElement) (uuid.UUID, bool) {
 	return surveyId, true
 }
 
+func validationFailed(c echo.Context, verrs models.ValidationErrors) error {
+	return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+		"result": "invalid",
+		"errors": verrs,
+	})
+}
+
+func isAdmin(claims microauth.JwtClaim) bool {
+	return microauth.Contains_string(claims.Roles, "ADMIN")
+}
+
+// authorizeAssignment verifies that an assignment belongs to the survey in the url and that the
+// requesting user is either the assignee or manages the survey
+func (sh *SurveyHandler) authorizeAssignment(c echo.Context, surveyId uuid.UUID, saId uuid.UUID) (models.SurveyAssignment, error) {
+	claims := c.Get("NSIUSER").(microauth.JwtClaim)
+	sa, err := sh.store.GetSurveyAssignment(surveyId, saId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return sa, echo.NewHTTPError(http.StatusNotFound, "Assignment not found")
+		}
+		return sa, err
+	}
+	if sa.Assigned != claims.Sub && !isAdmin(claims) && !sh.store.IsOwner(surveyId, claims.Sub) {
+		return sa, echo.NewHTTPError(http.StatusForbidden, "Assignment belongs to another user")
+	}
+	return sa, nil
+}
+
+// ifMatchVersion reads the result version a client expects to replace from the If-Match header
+func ifMatchVersion(c echo.Context) (*int, error) {
+	etag := c.Request().Header.Get("If-Match")
+	if etag == "" || etag == "*" {
+		return nil, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid If-Match header")
+	}
+	return &version, nil
+}
+
+func setETag(c echo.Context, version int) {
+	c.Response().Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// versionConflict responds with both the stored and submitted versions of a result so the client can reconcile them
+func (sh *SurveyHandler) versionConflict(c echo.Context, sa models.SurveyAssignment, submitted models.SurveyStructure) error {
+	current, err := sh.store.GetStructure(sa.SurveyElement_ID, sa.ID)
+	if err != nil {
+		return err
+	}
+	setETag(c, current.Version)
+	return c.JSON(http.StatusConflict, map[string]interface{}{
+		"result":    "conflict",
+		"current":   current,
+		"submitted": submitted,
+	})
+}
+
 // Checks surveyId in body matches with surveyId passed by URI
 // Do not use with handlers where surveyId isn't an expected URI param
 func validateUrl(surveyId uuid.UUID, c echo.Context) bool {
@@ -7,9 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/blobs"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
 	"github.com/google/uuid"
@@ -50,7 +52,7 @@ func TestUpdateSurvey(t *testing.T) {
 }
 
 func TestInsertSurveyMember(t *testing.T) {
-	payload := fmt.Sprintf(`{"surveyId":"%s","userId":"987654","isOwner":false}`, newSurveyId)
+	payload := fmt.Sprintf(`{"surveyId":"%s","userId":"987656","isOwner":false}`, newSurveyId)
 	rec, c := buildContext(http.MethodPost, payload, "987654")
 	c.SetParamNames("surveyid")
 	c.SetParamValues(newSurveyId)
@@ -162,7 +164,7 @@ func saveSurveyAssignment(userId string, t *testing.T) {
 		t.Log("No Assigned Structure Found")
 	} else {
 		if assert.NoError(t, err) {
-			structure.FoundHt = 999.0
+			structure.FoundHt = 9.5
 			structure.NoStreetView = true
 			json, err := json.Marshal(structure)
 			if err != nil {
@@ -170,6 +172,8 @@ func saveSurveyAssignment(userId string, t *testing.T) {
 			}
 			payload := string(json)
 			rec, c := buildContext(http.MethodPost, payload, userId)
+			c.SetParamNames("surveyid")
+			c.SetParamValues(newSurveyId)
 			h := buildHandler(t)
 			if assert.NoError(t, h.SaveSurveyAssignment(c)) {
 				assert.Equal(t, http.StatusOK, rec.Code)
@@ -259,7 +263,11 @@ func getClaims(userId string) microauth.JwtClaim {
 func buildHandler(t *testing.T) *SurveyHandler {
 	ds := getDataStore()
 	surveystore := &stores.SurveyStore{ds}
-	return CreateSurveyHandler(surveystore)
+	bs, err := blobs.NewLocalBlobStore(filepath.Join(os.TempDir(), "nsi-survey-attachments"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return CreateSurveyHandler(surveystore, bs, 10485760)
 }
 
 func buildContext(method string, payload string, userId string) (*httptest.ResponseRecorder, echo.Context) {
@@ -278,9 +286,9 @@ func getStructure(userId string) (models.SurveyStructure, error) {
 	ds := getDataStore()
 	ss := stores.SurveyStore{ds}
 	sid, _ := uuid.Parse(newSurveyId)
-	assignmentInfo, err := ss.GetAssignmentInfo(userId, sid)
-	if assignmentInfo.SEID != nil && assignmentInfo.SAID != nil {
-		structure, sterr = ss.GetStructure(*assignmentInfo.SEID, *assignmentInfo.SAID)
+	open, err := ss.GetMemberAssignments(userId, sid, "open", 1, 0)
+	if len(open) > 0 {
+		structure, sterr = ss.GetStructure(open[0].SEID, open[0].SAID)
 		if sterr != nil {
 			if err == nil {
 				err = sterr
@@ -289,3 +297,68 @@ func getStructure(userId string) (models.SurveyStructure, error) {
 	}
 	return structure, err
 }
+
+// createTestSurvey creates a survey owned by 987654 with nine elements, the fifth and seventh of them controls
+func createTestSurvey(t *testing.T, h *SurveyHandler) uuid.UUID {
+	surveyId, err := h.store.CreateNewSurvey(models.Survey{Title: "Survey Test " + uuid.New().String(), Active: true}, "987654")
+	if err != nil {
+		t.Fatal(err)
+	}
+	elements := make([]models.SurveyElement, 9)
+	for i := range elements {
+		elements[i] = models.SurveyElement{SurveyID: surveyId, SurveyOrder: i + 1, FD_ID: 95009 - i, Is_control: i == 4 || i == 6}
+	}
+	if err := h.store.InsertSurveyElements(&elements); err != nil {
+		t.Fatal(err)
+	}
+	return surveyId
+}
+
+// addTestMember adds a user to a survey with a role
+func addTestMember(t *testing.T, h *SurveyHandler, surveyId uuid.UUID, userId string, role string) {
+	err := h.store.UpsertSurveyMember(models.SurveyMember{SurveyID: surveyId, UserID: userId, Role: role, IsOwner: role == models.RoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testElement(t *testing.T, surveyId uuid.UUID, surveyOrder int) models.SurveyElement {
+	se := models.SurveyElement{}
+	err := getDataStore().Select("select id,survey_id,survey_order,fd_id,is_control from survey_element where survey_id=$1 and survey_order=$2").
+		Params(surveyId, surveyOrder).
+		Dest(&se).
+		Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return se
+}
+
+// surveyContext builds a request for a route addressing a survey.  Further path parameters are given as
+// name, value pairs.
+func surveyContext(method string, target string, payload string, userId string, surveyId uuid.UUID, params ...string) (*httptest.ResponseRecorder, echo.Context) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("NSIUSER", getClaims(userId))
+	c.Set("NSISURVEY", surveyId)
+	names := []string{"surveyid"}
+	values := []string{surveyId.String()}
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	return rec, c
+}
+
+// httpStatus returns the status code of an error returned by a handler
+func httpStatus(err error) int {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return he.Code
+	}
+	return 0
+}
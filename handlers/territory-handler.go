@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type territoryCoverageReport struct {
+	Territories []models.TerritoryCoverage `json:"territories"`
+	Outside     models.TerritoryCoverage   `json:"outside"`
+}
+
+//Lists the territories defined for a survey as a JSON array.  Each territory lists its polygon as [longitude,latitude]
+//vertices, its census block groups, and the user ids of the members bound to it.
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetTerritories(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	territories, err := sh.store.GetTerritories(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, territories)
+}
+
+//Creates a territory in a survey.  A territory is a polygon of [longitude,latitude] vertices, a list of 12 digit census
+//block group FIPS codes, or both.  Members bound to a territory are only assigned regular elements whose NSI location
+//falls within one of their territories; members without a territory may be assigned any element.
+//Returns the new territory as JSON with an HTTP CREATED (201) result on success.
+//
+//e.g. {"name":"Crew A","polygon":[[-90.1,29.9],[-90.0,29.9],[-90.0,30.0]],"blockGroups":["220710001001"]}
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) CreateTerritory(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	territory := models.Territory{}
+	if err := c.Bind(&territory); err != nil {
+		return err
+	}
+	territory.SurveyID = surveyId
+	if verrs := territory.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	id, err := sh.store.InsertTerritory(territory)
+	if err != nil {
+		return err
+	}
+	territory, err = sh.store.GetTerritory(surveyId, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, territory)
+}
+
+//Replaces the name, polygon, and block groups of a territory.  Members are unchanged.
+//Returns the updated territory as JSON on success.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) UpdateTerritory(c echo.Context) error {
+	surveyId, territoryId, err := territoryParams(c)
+	if err != nil {
+		return err
+	}
+	territory := models.Territory{}
+	if err := c.Bind(&territory); err != nil {
+		return err
+	}
+	territory.ID = territoryId
+	territory.SurveyID = surveyId
+	if verrs := territory.Validate(); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	err = sh.store.UpdateTerritory(territory)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Territory not found")
+		}
+		return err
+	}
+	territory, err = sh.store.GetTerritory(surveyId, territoryId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, territory)
+}
+
+//Deletes a territory.  Its members may be assigned any element unless they are bound to another territory.
+//Returns an empty HTTP OK result on success.
+//
+//PRIVATE API restricted to the ADMIN or SURVEY_OWNER roles
+func (sh *SurveyHandler) DeleteTerritory(c echo.Context) error {
+	surveyId, territoryId, err := territoryParams(c)
+	if err != nil {
+		return err
+	}
+	err = sh.store.DeleteTerritory(surveyId, territoryId)
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Territory not found")
+		}
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
+
+//Replaces the members bound to a territory.  The payload is a JSON array of user ids, each of which must be a member
+//of the survey.  An empty array unbinds every member.  Returns an empty HTTP OK result on success.
+//
+//e.g. ["987654","987655"]
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, or SURVEY_COORDINATOR roles
+func (sh *SurveyHandler) SetTerritoryMembers(c echo.Context) error {
+	surveyId, territoryId, err := territoryParams(c)
+	if err != nil {
+		return err
+	}
+	userIds := []string{}
+	if err := c.Bind(&userIds); err != nil {
+		return err
+	}
+	if _, err := sh.store.GetTerritory(surveyId, territoryId); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "Territory not found")
+		}
+		return err
+	}
+	verrs := models.ValidationErrors{}
+	for i, userId := range userIds {
+		if !sh.store.IsMember(surveyId, userId) {
+			verrs = append(verrs, models.FieldError{Field: fmt.Sprintf("members[%d]", i), Message: fmt.Sprintf("%s is not a member of the survey", userId)})
+		}
+	}
+	if len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	err = sh.store.SetTerritoryMembers(territoryId, userIds)
+	if err != nil {
+		return err
+	}
+	return c.String(http.StatusOK, "")
+}
+
+//Reports the progress of the regular elements within each territory of a survey: the number of bound members and the
+//number of elements, assigned elements, and completed elements.  Elements within more than one territory are counted
+//in each.  Elements outside every territory are summarized separately.
+//
+//e.g. {"territories":[{"territoryId":"...","name":"Crew A","members":2,"elements":340,"assigned":120,"completed":97}],"outside":{...}}
+//
+//PRIVATE API restricted to the ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, and SURVEY_VIEWER roles
+func (sh *SurveyHandler) GetTerritoryCoverage(c echo.Context) error {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return err
+	}
+	coverage, outside, err := sh.store.GetTerritoryCoverage(surveyId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, territoryCoverageReport{Territories: coverage, Outside: outside})
+}
+
+func territoryParams(c echo.Context) (uuid.UUID, uuid.UUID, error) {
+	surveyId, err := uuid.Parse(c.Param("surveyid"))
+	if err != nil {
+		return surveyId, uuid.Nil, err
+	}
+	territoryId, err := uuid.Parse(c.Param("territoryid"))
+	return surveyId, territoryId, err
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
+	"github.com/labstack/echo/v4"
+)
+
+type userActive struct {
+	Active bool `json:"active"`
+}
+
+//Lists the user directory as a JSON array ordered by name.  Profiles are refreshed from the token claims each time a
+//user authenticates.
+//
+//q: optional, matches part of the user id, name, or email
+//active: optional, true or false
+//r: rows per page (default 50)
+//p: zero based page (default 0)
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) GetUsers(c echo.Context) error {
+	active := c.QueryParam("active")
+	if active != "" && active != "true" && active != "false" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+	}
+	rows, page := 50, 0
+	var err error
+	if r := c.QueryParam("r"); r != "" {
+		if rows, err = strconv.Atoi(r); err != nil || rows < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+		}
+	}
+	if p := c.QueryParam("p"); p != "" {
+		if page, err = strconv.Atoi(p); err != nil || page < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid Query Parameters")
+		}
+	}
+	users, err := sh.store.GetUsers(c.QueryParam("q"), active, rows, page)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+//Gets a user's profile and their survey memberships with open and completed assignment counts as JSON.
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) GetUser(c echo.Context) error {
+	user, err := sh.store.GetUser(c.Param("userid"))
+	if err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+//Activates or deactivates a user.  Deactivated users keep their memberships, assignments, and results, and may finish
+//their open assignments, but are not issued new ones.  Returns the user as JSON on success.
+//
+//e.g. {"active":false}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) SetUserActive(c echo.Context) error {
+	userId := c.Param("userid")
+	body := userActive{}
+	if err := c.Bind(&body); err != nil {
+		return err
+	}
+	if err := sh.store.SetUserActive(userId, body.Active); err != nil {
+		if err.Error() == stores.NoResults {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return err
+	}
+	return sh.GetUser(c)
+}
+
+//Merges a duplicate user record into another user.  Every assignment, result, attachment, comment, flag, new structure,
+//invitation, and territory binding of the user is moved to the user named by into, memberships are combined keeping
+//the more privileged role, and the duplicate user is deleted.  Where both users hold the same element the completed
+//assignment is kept, or else the remaining user's, and the other is dropped with its result and attachments.  A user that authenticates again with the merged id is
+//recreated.  Returns the remaining user as JSON on success.
+//
+//e.g. POST /users/{userid}/merge {"into":"987654"}
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) MergeUsers(c echo.Context) error {
+	userId := c.Param("userid")
+	merge := models.UserMerge{}
+	if err := c.Bind(&merge); err != nil {
+		return err
+	}
+	if verrs := merge.Validate(userId); len(verrs) > 0 {
+		return validationFailed(c, verrs)
+	}
+	for _, id := range []string{userId, merge.Into} {
+		if _, err := sh.store.IsActiveUser(id); err != nil {
+			if err.Error() == stores.NoResults {
+				return echo.NewHTTPError(http.StatusNotFound, "User not found: "+id)
+			}
+			return err
+		}
+	}
+	attachments, err := sh.store.MergeUsers(userId, merge.Into)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		sh.deleteAttachmentBlobs(a)
+	}
+	user, err := sh.store.GetUser(merge.Into)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+//Reports the hits, misses, and hit rate of the survey member role cache used to authorize requests, along with the
+//number of cached roles and their time to live (RoleCacheTTL).
+//
+//PRIVATE API restricted to the ADMIN role
+func (sh *SurveyHandler) GetAuthCacheStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, stores.GetRoleCacheStats())
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeUsersSharingElements(t *testing.T) {
+	h := buildHandler(t)
+	surveyId := createTestSurvey(t, h)
+	from := "merge-" + uuid.New().String()[:8]
+	if _, err := h.store.RefreshUser(models.UserProfile{UserID: from, Username: from}, nil); err != nil {
+		t.Fatal(err)
+	}
+	addTestMember(t, h, surveyId, from, models.RoleSurveyor)
+	addTestMember(t, h, surveyId, "987655", models.RoleSurveyor)
+	assign := func(order int, userId string, completed bool) uuid.UUID {
+		var id uuid.UUID
+		err := getDataStore().Select("insert into survey_assignment (se_id,assigned_to,completed) values ($1,$2,$3) returning id").
+			Params(testElement(t, surveyId, order).ID, userId, completed).
+			Dest(&id).
+			Fetch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	completedControl := assign(5, from, true)
+	assign(5, "987655", false)
+	assign(7, from, false)
+	openControl := assign(7, "987655", false)
+
+	_, err := h.store.MergeUsers(from, "987655")
+	assert.Nil(t, err)
+	for order, kept := range map[int]uuid.UUID{5: completedControl, 7: openControl} {
+		ids := []uuid.UUID{}
+		err := getDataStore().Select("select id from survey_assignment where se_id=$1").
+			Params(testElement(t, surveyId, order).ID).
+			Dest(&ids).
+			Fetch()
+		assert.Nil(t, err)
+		assert.Equal(t, []uuid.UUID{kept}, ids, "element %d", order)
+	}
+	var assignedTo string
+	err = getDataStore().Select("select assigned_to from survey_assignment where id=$1").
+		Params(completedControl).
+		Dest(&assignedTo).
+		Fetch()
+	assert.Nil(t, err)
+	assert.Equal(t, "987655", assignedTo)
+}
@@ -9,59 +9,157 @@ import (
 	"github.com/usace/microauth"
 
 	. "github.com/HydrologicEngineeringCenter/nsi_survey_server/auth"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/blobs"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/config"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/global"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/handlers"
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
 	"github.com/HydrologicEngineeringCenter/nsi_survey_server/stores"
 )
 
-const urlPrefix = "nsisapi"
+const urlPrefix = global.URL_PREFIX
 
 func main() {
 	var cfg config.Config
 	if err := envconfig.Process("", &cfg); err != nil {
 		log.Fatal(err.Error())
 	}
-	cfg.SkipJWT = true
-
 	ss, err := stores.CreateSurveyStore(&cfg)
 	if err != nil {
 		log.Printf("Unable to connect to database during startup: %s", err)
 	}
+	stores.SetRoleCacheTTL(cfg.RoleCacheTTL)
+
+	bs, err := blobs.NewLocalBlobStore(cfg.BlobRoot)
+	if err != nil {
+		log.Fatalf("Unable to open attachment store %s: %s", cfg.BlobRoot, err)
+	}
 
-	surveyHandler := handlers.CreateSurveyHandler(ss)
-	auth := microauth.Auth{
+	surveyHandler := handlers.CreateSurveyHandler(ss, bs, cfg.MaxAttachmentSize)
+	mauth := microauth.Auth{
 		AuthRoute: Appauth,
 		Aud:       cfg.Aud,
 		Store:     ss,
 	}
-	auth.LoadVerificationKey(microauth.VerificationKeyOptions{
-		KeySource: microauth.KeyFile,
-		KeyVal:    cfg.Ippk,
-	},
-	)
+	var auth RouteAuthorizer = &mauth
+	var devAuth *DevAuth
+	if cfg.DevAuth && (cfg.Ippk != "" || cfg.Jwks != "") {
+		log.Fatal("DevAuth cannot be enabled together with Ippk or Jwks")
+	}
+	switch {
+	case cfg.DevAuth:
+		log.Printf("WARNING: development authentication is enabled; tokens are self issued from %s/dev/token", urlPrefix)
+		if devAuth, err = NewDevAuth(&mauth, cfg.DevTokenTTL); err != nil {
+			log.Fatalf("Unable to start development authentication: %s", err)
+		}
+	case cfg.Jwks != "":
+		keys, err := NewKeySet(cfg.Jwks, cfg.JwksRefresh)
+		if err != nil {
+			log.Fatalf("Unable to load JWKS from %s: %s", cfg.Jwks, err)
+		}
+		auth = &JwksAuth{
+			Keys:      keys,
+			Issuers:   cfg.Issuers,
+			Audiences: cfg.TrustedAudiences(),
+			AuthRoute: Appauth,
+			Store:     ss,
+		}
+	default:
+		mauth.LoadVerificationKey(microauth.VerificationKeyOptions{
+			KeySource: microauth.KeyFile,
+			KeyVal:    cfg.Ippk,
+		},
+		)
+	}
+
+	apiKeys := &KeyAuth{Next: auth, Store: ss}
 
 	e := echo.New()
 
 	//e.Use(jwtAuth.AuthorizeMiddleware)
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	e.Use(surveyHandler.ArchiveGuard)
 
 	e.GET(urlPrefix+"/version", surveyHandler.Version)
+	if devAuth != nil {
+		e.POST(urlPrefix+"/dev/token", devAuth.IssueToken)
+	}
 	e.GET(urlPrefix+"/surveys", auth.AuthorizeRoute(surveyHandler.GetSurveysForUser, PUBLIC))
 	e.POST(urlPrefix+"/survey", auth.AuthorizeRoute(surveyHandler.CreateNewSurvey, ADMIN, PUBLIC))
 	e.PUT(urlPrefix+"/survey/:surveyid", auth.AuthorizeRoute(surveyHandler.UpdateSurvey, ADMIN, SURVEY_OWNER))
-	e.GET(urlPrefix+"/survey/:surveyid/members", auth.AuthorizeRoute(surveyHandler.GetSurveyMembers, ADMIN, SURVEY_OWNER))
-	e.POST(urlPrefix+"/survey/:surveyid/member", auth.AuthorizeRoute(surveyHandler.UpsertSurveyMember, ADMIN, SURVEY_OWNER))
-	e.DELETE(urlPrefix+"/survey/member/:memberid", auth.AuthorizeRoute(surveyHandler.RemoveSurveyMember, ADMIN, SURVEY_OWNER))
-	e.DELETE(urlPrefix+"/survey/:surveyid/member/:memberid", auth.AuthorizeRoute(surveyHandler.RemoveMemberFromSurvey, ADMIN, SURVEY_OWNER))
-	e.GET(urlPrefix+"/survey/:surveyid/elements", auth.AuthorizeRoute(surveyHandler.GetSurveyElements, ADMIN, SURVEY_OWNER))
-	e.POST(urlPrefix+"/survey/:surveyid/elements", auth.AuthorizeRoute(surveyHandler.InsertSurveyElements, ADMIN, SURVEY_OWNER))
-	e.POST(urlPrefix+"/survey/:surveyid/assignments", auth.AuthorizeRoute(surveyHandler.AddAssignments, ADMIN, SURVEY_OWNER))
-	e.GET(urlPrefix+"/survey/:surveyid/assignment", auth.AuthorizeRoute(surveyHandler.AssignSurveyElement, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
-	e.POST(urlPrefix+"/survey/:surveyid/assignment", auth.AuthorizeRoute(surveyHandler.SaveSurveyAssignment, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.DELETE(urlPrefix+"/survey/:surveyid", auth.AuthorizeRoute(surveyHandler.PurgeSurvey, ADMIN))
+	e.PUT(urlPrefix+"/survey/:surveyid/archive", auth.AuthorizeRoute(surveyHandler.ArchiveSurvey, ADMIN, SURVEY_OWNER))
+	e.DELETE(urlPrefix+"/survey/:surveyid/archive", auth.AuthorizeRoute(surveyHandler.RestoreSurvey, ADMIN, SURVEY_OWNER))
+	e.POST(urlPrefix+"/survey/:surveyid/clone", auth.AuthorizeRoute(surveyHandler.CloneSurvey, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/export", auth.AuthorizeRoute(surveyHandler.ExportSurvey, ADMIN, SURVEY_OWNER))
+	e.POST(urlPrefix+"/survey/import", auth.AuthorizeRoute(surveyHandler.ImportSurvey, ADMIN))
+	e.GET(urlPrefix+"/survey/:surveyid/members", auth.AuthorizeRoute(surveyHandler.GetSurveyMembers, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.POST(urlPrefix+"/survey/:surveyid/member", auth.AuthorizeRoute(surveyHandler.UpsertSurveyMember, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.POST(urlPrefix+"/survey/:surveyid/members/import", auth.AuthorizeRoute(surveyHandler.ImportSurveyMembers, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.DELETE(urlPrefix+"/survey/member/:memberid", auth.AuthorizeRoute(surveyHandler.RemoveSurveyMember, ADMIN))
+	e.DELETE(urlPrefix+"/survey/:surveyid/member/:memberid", auth.AuthorizeRoute(surveyHandler.RemoveMemberFromSurvey, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.GET(urlPrefix+"/survey/:surveyid/invitations", auth.AuthorizeRoute(surveyHandler.GetInvitations, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.POST(urlPrefix+"/survey/:surveyid/invitations", auth.AuthorizeRoute(surveyHandler.CreateInvitation, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.DELETE(urlPrefix+"/survey/:surveyid/invitations/:invitationid", auth.AuthorizeRoute(surveyHandler.RevokeInvitation, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.GET(urlPrefix+"/survey/:surveyid/groups", auth.AuthorizeRoute(surveyHandler.GetSurveyGroups, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.PUT(urlPrefix+"/survey/:surveyid/groups/:group", auth.AuthorizeRoute(surveyHandler.UpsertSurveyGroup, ADMIN, SURVEY_OWNER))
+	e.DELETE(urlPrefix+"/survey/:surveyid/groups/:group", auth.AuthorizeRoute(surveyHandler.DeleteSurveyGroup, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/elements", apiKeys.AuthorizeKeyRoute(models.PermElementsRead, surveyHandler.GetSurveyElements, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.POST(urlPrefix+"/survey/:surveyid/elements", apiKeys.AuthorizeKeyRoute(models.PermElementsWrite, surveyHandler.InsertSurveyElements, ADMIN, SURVEY_OWNER))
+	e.POST(urlPrefix+"/survey/:surveyid/assignments", apiKeys.AuthorizeKeyRoute(models.PermAssignmentsWrite, surveyHandler.AddAssignments, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.GET(urlPrefix+"/survey/:surveyid/assignment", auth.AuthorizeRoute(surveyHandler.AssignSurveyElement, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.POST(urlPrefix+"/survey/:surveyid/assignment", auth.AuthorizeRoute(surveyHandler.Idempotent(surveyHandler.SaveSurveyAssignment), ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.POST(urlPrefix+"/survey/:surveyid/assignment/draft", auth.AuthorizeRoute(surveyHandler.Idempotent(surveyHandler.SaveSurveyAssignmentDraft), ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.POST(urlPrefix+"/survey/:surveyid/checkout", auth.AuthorizeRoute(surveyHandler.CheckoutAssignments, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.POST(urlPrefix+"/survey/:surveyid/sync", auth.AuthorizeRoute(surveyHandler.Idempotent(surveyHandler.SyncAssignments), ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.GET(urlPrefix+"/survey/:surveyid/my-assignments", auth.AuthorizeRoute(surveyHandler.GetMyAssignments, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.GET(urlPrefix+"/survey/:surveyid/my-assignments/:said", auth.AuthorizeRoute(surveyHandler.GetMyAssignment, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.POST(urlPrefix+"/survey/:surveyid/my-assignments/:said/reopen", auth.AuthorizeRoute(surveyHandler.ReopenMyAssignment, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
 	e.GET(urlPrefix+"/users/search", auth.AuthorizeRoute(surveyHandler.SearchUsers, PUBLIC))
+	e.GET(urlPrefix+"/users", auth.AuthorizeRoute(surveyHandler.GetUsers, ADMIN))
+	e.GET(urlPrefix+"/users/:userid", auth.AuthorizeRoute(surveyHandler.GetUser, ADMIN))
+	e.PUT(urlPrefix+"/users/:userid/active", auth.AuthorizeRoute(surveyHandler.SetUserActive, ADMIN))
+	e.POST(urlPrefix+"/users/:userid/merge", auth.AuthorizeRoute(surveyHandler.MergeUsers, ADMIN))
+	e.GET(urlPrefix+"/service-accounts", auth.AuthorizeRoute(surveyHandler.GetServiceAccounts, ADMIN))
+	e.POST(urlPrefix+"/service-accounts", auth.AuthorizeRoute(surveyHandler.CreateServiceAccount, ADMIN))
+	e.PUT(urlPrefix+"/service-accounts/:accountid/active", auth.AuthorizeRoute(surveyHandler.SetServiceAccountActive, ADMIN))
+	e.GET(urlPrefix+"/service-accounts/:accountid/keys", auth.AuthorizeRoute(surveyHandler.GetAPIKeys, ADMIN))
+	e.POST(urlPrefix+"/service-accounts/:accountid/keys", auth.AuthorizeRoute(surveyHandler.CreateAPIKey, ADMIN))
+	e.DELETE(urlPrefix+"/service-accounts/:accountid/keys/:keyid", auth.AuthorizeRoute(surveyHandler.RevokeAPIKey, ADMIN))
+	e.GET(urlPrefix+"/metrics/auth-cache", auth.AuthorizeRoute(surveyHandler.GetAuthCacheStats, ADMIN))
 	e.GET(urlPrefix+"/survey/valid", auth.AuthorizeRoute(surveyHandler.ValidSurveyName, PUBLIC))
-	e.GET(urlPrefix+"/survey/:surveyid/report", auth.AuthorizeRoute(surveyHandler.GetSurveyReport, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/report", apiKeys.AuthorizeKeyRoute(models.PermReportRead, surveyHandler.GetSurveyReport, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.GET(urlPrefix+"/survey/:surveyid/report/geojson", apiKeys.AuthorizeKeyRoute(models.PermReportRead, surveyHandler.GetSurveyReportGeoJSON, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.GET(urlPrefix+"/dictionary", surveyHandler.GetDictionary)
+	e.GET(urlPrefix+"/survey/:surveyid/dictionary", auth.AuthorizeRoute(surveyHandler.GetSurveyDictionary, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.PUT(urlPrefix+"/survey/:surveyid/dictionary/:column", auth.AuthorizeRoute(surveyHandler.UpdateSurveyDomain, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/settings", auth.AuthorizeRoute(surveyHandler.GetSurveySettings, ADMIN, SURVEY_OWNER))
+	e.PUT(urlPrefix+"/survey/:surveyid/settings", auth.AuthorizeRoute(surveyHandler.UpdateSurveySettings, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/territories", auth.AuthorizeRoute(surveyHandler.GetTerritories, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.POST(urlPrefix+"/survey/:surveyid/territories", auth.AuthorizeRoute(surveyHandler.CreateTerritory, ADMIN, SURVEY_OWNER))
+	e.GET(urlPrefix+"/survey/:surveyid/territories/coverage", auth.AuthorizeRoute(surveyHandler.GetTerritoryCoverage, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.PUT(urlPrefix+"/survey/:surveyid/territories/:territoryid", auth.AuthorizeRoute(surveyHandler.UpdateTerritory, ADMIN, SURVEY_OWNER))
+	e.DELETE(urlPrefix+"/survey/:surveyid/territories/:territoryid", auth.AuthorizeRoute(surveyHandler.DeleteTerritory, ADMIN, SURVEY_OWNER))
+	e.PUT(urlPrefix+"/survey/:surveyid/territories/:territoryid/members", auth.AuthorizeRoute(surveyHandler.SetTerritoryMembers, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR))
+	e.POST(urlPrefix+"/survey/:surveyid/assignment/:said/attachments", auth.AuthorizeRoute(surveyHandler.UploadAttachment, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.GET(urlPrefix+"/survey/:surveyid/assignment/:said/attachments", auth.AuthorizeRoute(surveyHandler.GetAssignmentAttachments, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.GET(urlPrefix+"/survey/:surveyid/attachment/:attachmentid", auth.AuthorizeRoute(surveyHandler.GetAttachment, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.DELETE(urlPrefix+"/survey/:surveyid/attachment/:attachmentid", auth.AuthorizeRoute(surveyHandler.DeleteAttachment, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.POST(urlPrefix+"/survey/:surveyid/assignment/:said/comments", auth.AuthorizeRoute(surveyHandler.AddComment, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.GET(urlPrefix+"/survey/:surveyid/assignment/:said/comments", auth.AuthorizeRoute(surveyHandler.GetComments, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.POST(urlPrefix+"/survey/:surveyid/assignment/:said/flags", auth.AuthorizeRoute(surveyHandler.RaiseFlag, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.GET(urlPrefix+"/survey/:surveyid/assignment/:said/flags", auth.AuthorizeRoute(surveyHandler.GetElementFlags, ADMIN, SURVEY_OWNER, SURVEY_MEMBER))
+	e.POST(urlPrefix+"/survey/:surveyid/element/:seid/comments", auth.AuthorizeRoute(surveyHandler.AddComment, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.GET(urlPrefix+"/survey/:surveyid/element/:seid/comments", auth.AuthorizeRoute(surveyHandler.GetComments, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.POST(urlPrefix+"/survey/:surveyid/element/:seid/flags", auth.AuthorizeRoute(surveyHandler.RaiseFlag, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.GET(urlPrefix+"/survey/:surveyid/element/:seid/flags", auth.AuthorizeRoute(surveyHandler.GetElementFlags, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.GET(urlPrefix+"/survey/:surveyid/flags", auth.AuthorizeRoute(surveyHandler.GetSurveyFlags, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.PUT(urlPrefix+"/survey/:surveyid/flag/:flagid/resolve", auth.AuthorizeRoute(surveyHandler.ResolveFlag, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.POST(urlPrefix+"/survey/:surveyid/structures", auth.AuthorizeRoute(surveyHandler.SubmitNewStructure, ADMIN, SURVEY_OWNER, SURVEY_SURVEYOR))
+	e.GET(urlPrefix+"/survey/:surveyid/structures", auth.AuthorizeRoute(surveyHandler.GetNewStructures, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
+	e.PUT(urlPrefix+"/survey/:surveyid/structure/:structureid/review", auth.AuthorizeRoute(surveyHandler.ReviewNewStructure, ADMIN, SURVEY_OWNER, SURVEY_REVIEWER))
+	e.GET(urlPrefix+"/survey/:surveyid/structures/report", apiKeys.AuthorizeKeyRoute(models.PermReportRead, surveyHandler.GetNewStructureReport, ADMIN, SURVEY_OWNER, SURVEY_COORDINATOR, SURVEY_REVIEWER, SURVEY_VIEWER))
 
 	e.Logger.Fatal(e.Start(":" + cfg.Port))
 
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Attachment struct {
+	ID           uuid.UUID `db:"id" json:"id"`
+	SAID         uuid.UUID `db:"sa_id" json:"saId"`
+	FileName     string    `db:"file_name" json:"fileName"`
+	ContentType  string    `db:"content_type" json:"contentType"`
+	Size         int64     `db:"size" json:"size"`
+	BlobKey      string    `db:"blob_key" json:"-"`
+	ThumbnailKey string    `db:"thumbnail_key" json:"-"`
+	Note         string    `db:"note" json:"note"`
+	UploadedBy   string    `db:"uploaded_by" json:"uploadedBy"`
+	UploadedAt   time.Time `db:"uploaded_at" json:"uploadedAt"`
+}
+
+// AttachmentLink is the attachment reference included in survey listings and exports
+type AttachmentLink struct {
+	ID           uuid.UUID `json:"id"`
+	FileName     string    `json:"fileName"`
+	ContentType  string    `json:"contentType"`
+	Note         string    `json:"note"`
+	Url          string    `json:"url,omitempty"`
+	ThumbnailUrl string    `json:"thumbnailUrl,omitempty"`
+}
+
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string       `json:"type"`
+	Geometry   GeoJSONPoint `json:"geometry"`
+	Properties interface{}  `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+func NewPointFeature(x float64, y float64, properties interface{}) GeoJSONFeature {
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   GeoJSONPoint{Type: "Point", Coordinates: [2]float64{x, y}},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArchiveFormatVersion is the version of the survey archive layout written by export.  Import accepts
+// archives up to this version.
+const ArchiveFormatVersion = 1
+
+// ArchiveManifest describes a survey archive
+type ArchiveManifest struct {
+	FormatVersion  int            `json:"formatVersion"`
+	ServerVersion  string         `json:"serverVersion"`
+	ExportedAt     time.Time      `json:"exportedAt"`
+	SourceSurveyID uuid.UUID      `json:"sourceSurveyId"`
+	Counts         map[string]int `json:"counts"`
+}
+
+type ArchiveAssignment struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	SEID       uuid.UUID `db:"se_id" json:"seId"`
+	Completed  bool      `db:"completed" json:"completed"`
+	AssignedTo string    `db:"assigned_to" json:"assignedTo"`
+	AssignedAt time.Time `db:"assigned_at" json:"assignedAt"`
+}
+
+// ArchiveResult is a survey result as exported.  Nullable columns are pointers so that a missing value
+// round trips as null rather than as a zero value.
+type ArchiveResult struct {
+	ID               uuid.UUID `db:"id" json:"id"`
+	SAID             uuid.UUID `db:"sa_id" json:"saId"`
+	FDID             int       `db:"fd_id" json:"fdId"`
+	X                float64   `db:"x" json:"x"`
+	Y                float64   `db:"y" json:"y"`
+	InvalidStructure bool      `db:"invalid_structure" json:"invalidStructure"`
+	NoStreetView     bool      `db:"no_street_view" json:"noStreetView"`
+	CBfips           *string   `db:"cbfips" json:"cbfips"`
+	OccupancyType    *string   `db:"occtype" json:"occupancyType"`
+	Damcat           *string   `db:"st_damcat" json:"damcat"`
+	FoundHt          *float64  `db:"found_ht" json:"found_ht"`
+	Stories          *float64  `db:"num_story" json:"stories"`
+	SqFt             *float64  `db:"sqft" json:"sq_ft"`
+	FoundType        *string   `db:"found_type" json:"found_type"`
+	RsmeansType      *string   `db:"rsmeans_type" json:"rsmeans_type"`
+	Quality          *string   `db:"quality" json:"quality"`
+	ConstType        *string   `db:"const_type" json:"const_type"`
+	Garage           *string   `db:"garage" json:"garage"`
+	RoofStyle        *string   `db:"roof_style" json:"roof_style"`
+	Version          int       `db:"version" json:"version"`
+	UpdatedBy        *string   `db:"updated_by" json:"updatedBy"`
+	UpdatedAt        time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// SurveyArchive is a complete, portable copy of a survey.  Attachment content and new structure
+// submissions are not included.
+type SurveyArchive struct {
+	Survey      Survey              `json:"survey"`
+	Users       []User              `json:"users"`
+	Members     []SurveyMember      `json:"members"`
+	Elements    []SurveyElement     `json:"elements"`
+	Assignments []ArchiveAssignment `json:"assignments"`
+	Results     []ArchiveResult     `json:"results"`
+	Settings    *SurveySettings     `json:"settings"`
+	Domains     []SurveyDomainValue `json:"domains"`
+	Territories []Territory         `json:"territories"`
+	Comments    []Comment           `json:"comments"`
+	Flags       []Flag              `json:"flags"`
+}
+
+func (a SurveyArchive) Counts() map[string]int {
+	return map[string]int{
+		"users":       len(a.Users),
+		"members":     len(a.Members),
+		"elements":    len(a.Elements),
+		"assignments": len(a.Assignments),
+		"results":     len(a.Results),
+		"domains":     len(a.Domains),
+		"territories": len(a.Territories),
+		"comments":    len(a.Comments),
+		"flags":       len(a.Flags),
+	}
+}
+
+// Validate checks the referential integrity of an archive: every user, element, assignment, and
+// comment referenced must be included in the archive itself, and no member, control assignment, or
+// territory member may be repeated.  Archives are checked again after Remap since mapping two users
+// onto one can introduce repeats.
+func (a SurveyArchive) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if a.Survey.Title == "" {
+		errs.add("survey.title", "is required")
+	}
+	users := map[string]bool{}
+	for _, u := range a.Users {
+		users[u.UserID] = true
+	}
+	user := func(field string, userId string) {
+		if userId != "" && !users[userId] {
+			errs.add(field, "references unknown user %s", userId)
+		}
+	}
+	members := map[string]bool{}
+	for i, m := range a.Members {
+		user(fmt.Sprintf("members[%d].userId", i), m.UserID)
+		if m.Role != "" && !ValidRole(m.Role) {
+			errs.add(fmt.Sprintf("members[%d].role", i), "%s is not a valid role", m.Role)
+		}
+		if members[m.UserID] {
+			errs.add(fmt.Sprintf("members[%d].userId", i), "duplicate member %s", m.UserID)
+		}
+		members[m.UserID] = true
+	}
+	elements := map[uuid.UUID]bool{}
+	for i, e := range a.Elements {
+		if elements[e.ID] {
+			errs.add(fmt.Sprintf("elements[%d].seId", i), "duplicate element %s", e.ID)
+		}
+		elements[e.ID] = true
+	}
+	element := func(field string, seId uuid.UUID) {
+		if !elements[seId] {
+			errs.add(field, "references unknown element %s", seId)
+		}
+	}
+	assignments := map[uuid.UUID]bool{}
+	assigned := map[string]bool{}
+	for i, sa := range a.Assignments {
+		element(fmt.Sprintf("assignments[%d].seId", i), sa.SEID)
+		user(fmt.Sprintf("assignments[%d].assignedTo", i), sa.AssignedTo)
+		if sa.AssignedTo != "" {
+			key := sa.SEID.String() + "/" + sa.AssignedTo
+			if assigned[key] {
+				errs.add(fmt.Sprintf("assignments[%d].assignedTo", i), "element %s is already assigned to %s", sa.SEID, sa.AssignedTo)
+			}
+			assigned[key] = true
+		}
+		assignments[sa.ID] = true
+	}
+	assignment := func(field string, saId *uuid.UUID) {
+		if saId != nil && !assignments[*saId] {
+			errs.add(field, "references unknown assignment %s", *saId)
+		}
+	}
+	results := map[uuid.UUID]bool{}
+	for i, r := range a.Results {
+		field := fmt.Sprintf("results[%d]", i)
+		assignment(field+".saId", &r.SAID)
+		if results[r.SAID] {
+			errs.add(field+".saId", "duplicate result for assignment %s", r.SAID)
+		}
+		results[r.SAID] = true
+		if r.UpdatedBy != nil {
+			user(field+".updatedBy", *r.UpdatedBy)
+		}
+	}
+	for i, d := range a.Domains {
+		if _, ok := FindAttribute(StructureAttributes, d.Column); !ok {
+			errs.add(fmt.Sprintf("domains[%d].column", i), "%s is not a structure attribute", d.Column)
+		}
+	}
+	for i, t := range a.Territories {
+		field := fmt.Sprintf("territories[%d]", i)
+		for _, v := range t.Validate() {
+			errs.add(field+"."+v.Field, "%s", v.Message)
+		}
+		territoryMembers := map[string]bool{}
+		for j, userId := range t.Members {
+			if !members[userId] {
+				errs.add(fmt.Sprintf("%s.members[%d]", field, j), "%s is not a member of the survey", userId)
+			}
+			if territoryMembers[userId] {
+				errs.add(fmt.Sprintf("%s.members[%d]", field, j), "duplicate territory member %s", userId)
+			}
+			territoryMembers[userId] = true
+		}
+	}
+	comments := map[uuid.UUID]bool{}
+	for i, c := range a.Comments {
+		field := fmt.Sprintf("comments[%d]", i)
+		element(field+".seId", c.SEID)
+		assignment(field+".saId", c.SAID)
+		user(field+".userId", c.UserID)
+		if c.ParentID != nil && !comments[*c.ParentID] {
+			errs.add(field+".parentId", "references unknown or later comment %s", *c.ParentID)
+		}
+		comments[c.ID] = true
+	}
+	for i, f := range a.Flags {
+		field := fmt.Sprintf("flags[%d]", i)
+		element(field+".seId", f.SEID)
+		assignment(field+".saId", f.SAID)
+		user(field+".raisedBy", f.RaisedBy)
+		if f.ResolvedBy != nil {
+			user(field+".resolvedBy", *f.ResolvedBy)
+		}
+	}
+	return errs
+}
+
+// Remap returns a copy of the archive with every record given a new id and user ids replaced through
+// the users map.  Users missing from the map keep their id.
+func (a SurveyArchive) Remap(users map[string]string) SurveyArchive {
+	ids := map[uuid.UUID]uuid.UUID{}
+	id := func(old uuid.UUID) uuid.UUID {
+		if n, ok := ids[old]; ok {
+			return n
+		}
+		n := uuid.New()
+		ids[old] = n
+		return n
+	}
+	optionalId := func(old *uuid.UUID) *uuid.UUID {
+		if old == nil {
+			return nil
+		}
+		n := id(*old)
+		return &n
+	}
+	user := func(userId string) string {
+		if n, ok := users[userId]; ok {
+			return n
+		}
+		return userId
+	}
+
+	r := SurveyArchive{Survey: a.Survey}
+	r.Survey.ID = id(a.Survey.ID)
+	seen := map[string]bool{}
+	for _, u := range a.Users {
+		u.UserID = user(u.UserID)
+		if !seen[u.UserID] {
+			r.Users = append(r.Users, u)
+			seen[u.UserID] = true
+		}
+	}
+	for _, m := range a.Members {
+		m.ID = id(m.ID)
+		m.SurveyID = r.Survey.ID
+		m.UserID = user(m.UserID)
+		r.Members = append(r.Members, m)
+	}
+	for _, e := range a.Elements {
+		e.ID = id(e.ID)
+		e.SurveyID = r.Survey.ID
+		r.Elements = append(r.Elements, e)
+	}
+	for _, sa := range a.Assignments {
+		sa.ID = id(sa.ID)
+		sa.SEID = id(sa.SEID)
+		sa.AssignedTo = user(sa.AssignedTo)
+		r.Assignments = append(r.Assignments, sa)
+	}
+	for _, res := range a.Results {
+		res.ID = id(res.ID)
+		res.SAID = id(res.SAID)
+		if res.UpdatedBy != nil {
+			updatedBy := user(*res.UpdatedBy)
+			res.UpdatedBy = &updatedBy
+		}
+		r.Results = append(r.Results, res)
+	}
+	if a.Settings != nil {
+		settings := *a.Settings
+		settings.SurveyID = r.Survey.ID
+		r.Settings = &settings
+	}
+	for _, d := range a.Domains {
+		d.SurveyID = r.Survey.ID
+		r.Domains = append(r.Domains, d)
+	}
+	for _, t := range a.Territories {
+		t.ID = id(t.ID)
+		t.SurveyID = r.Survey.ID
+		members := make([]string, len(t.Members))
+		for i, userId := range t.Members {
+			members[i] = user(userId)
+		}
+		t.Members = members
+		r.Territories = append(r.Territories, t)
+	}
+	for _, c := range a.Comments {
+		c.ID = id(c.ID)
+		c.SurveyID = r.Survey.ID
+		c.SEID = id(c.SEID)
+		c.SAID = optionalId(c.SAID)
+		c.ParentID = optionalId(c.ParentID)
+		c.UserID = user(c.UserID)
+		r.Comments = append(r.Comments, c)
+	}
+	for _, f := range a.Flags {
+		f.ID = id(f.ID)
+		f.SurveyID = r.Survey.ID
+		f.SEID = id(f.SEID)
+		f.SAID = optionalId(f.SAID)
+		f.RaisedBy = user(f.RaisedBy)
+		if f.ResolvedBy != nil {
+			resolvedBy := user(*f.ResolvedBy)
+			f.ResolvedBy = &resolvedBy
+		}
+		r.Flags = append(r.Flags, f)
+	}
+	return r
+}
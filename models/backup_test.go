@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func testArchive() SurveyArchive {
+	surveyId, seId, saId, commentId := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	return SurveyArchive{
+		Survey:      Survey{ID: surveyId, Title: "Orleans Parish"},
+		Users:       []User{{"987654", "Randy Goss"}, {"987655", "Will Lehman"}},
+		Members:     []SurveyMember{{ID: uuid.New(), SurveyID: surveyId, UserID: "987654", IsOwner: true}},
+		Elements:    []SurveyElement{{ID: seId, SurveyID: surveyId, SurveyOrder: 1, FD_ID: 95009}},
+		Assignments: []ArchiveAssignment{{ID: saId, SEID: seId, Completed: true, AssignedTo: "987655"}},
+		Results:     []ArchiveResult{{ID: uuid.New(), SAID: saId, FDID: 95009, UpdatedBy: stringPtr("987655")}},
+		Comments: []Comment{
+			{ID: commentId, SurveyID: surveyId, SEID: seId, SAID: &saId, UserID: "987655", Body: "two buildings"},
+			{ID: uuid.New(), SurveyID: surveyId, SEID: seId, ParentID: &commentId, UserID: "987654", Body: "agreed"},
+		},
+	}
+}
+
+func TestArchiveValidation(t *testing.T) {
+	archive := testArchive()
+	assert.Empty(t, archive.Validate())
+
+	archive.Assignments[0].SEID = uuid.New()
+	archive.Results[0].UpdatedBy = stringPtr("000000")
+	archive.Comments[0], archive.Comments[1] = archive.Comments[1], archive.Comments[0]
+	assert.Equal(t, []string{"assignments[0].seId", "results[0].updatedBy", "comments[0].parentId"}, fields(archive.Validate()))
+}
+
+func TestArchiveRemap(t *testing.T) {
+	archive := testArchive()
+	remapped := archive.Remap(map[string]string{"987655": "123456"})
+	assert.Empty(t, remapped.Validate())
+	assert.NotEqual(t, archive.Survey.ID, remapped.Survey.ID)
+	assert.NotEqual(t, archive.Elements[0].ID, remapped.Elements[0].ID)
+	assert.Equal(t, remapped.Elements[0].ID, remapped.Assignments[0].SEID)
+	assert.Equal(t, remapped.Assignments[0].ID, remapped.Results[0].SAID)
+	assert.Equal(t, remapped.Comments[0].ID, *remapped.Comments[1].ParentID)
+	assert.Equal(t, "123456", remapped.Assignments[0].AssignedTo)
+	assert.Equal(t, "987654", remapped.Members[0].UserID)
+	assert.Equal(t, remapped.Survey.ID, remapped.Members[0].SurveyID)
+}
+
+func TestArchiveRemapCollisions(t *testing.T) {
+	archive := testArchive()
+	archive.Members = append(archive.Members, SurveyMember{ID: uuid.New(), SurveyID: archive.Survey.ID, UserID: "987655", Role: RoleSurveyor})
+	archive.Elements[0].Is_control = true
+	archive.Assignments = append(archive.Assignments, ArchiveAssignment{ID: uuid.New(), SEID: archive.Elements[0].ID, AssignedTo: "987654"})
+	archive.Territories = []Territory{{ID: uuid.New(), SurveyID: archive.Survey.ID, Name: "North", BlockGroups: []string{"220710001001"}, Members: []string{"987654", "987655"}}}
+	assert.Empty(t, archive.Validate())
+
+	remapped := archive.Remap(map[string]string{"987655": "987654"})
+	assert.Len(t, remapped.Users, 1)
+	assert.Equal(t, []string{"members[1].userId", "assignments[1].assignedTo", "territories[0].members[1]"}, fields(remapped.Validate()))
+}
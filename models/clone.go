@@ -0,0 +1,64 @@
+package models
+
+import "strings"
+
+const (
+	CloneMembersAll    = "all"
+	CloneMembersOwners = "owners"
+	CloneMembersNone   = "none"
+
+	CloneOrderKeep   = "keep"
+	CloneOrderRandom = "random"
+
+	CloneControlsKeep   = "keep"
+	CloneControlsNone   = "none"
+	CloneControlsRandom = "random"
+)
+
+// SurveyClone chooses the parts of a survey copied into a new survey.  Settings covers the assignment
+// settings, survey specific domains, and territories.
+type SurveyClone struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Members      string `json:"members"`
+	Elements     bool   `json:"elements"`
+	Order        string `json:"order"`
+	Controls     string `json:"controls"`
+	ControlCount int    `json:"controlCount"`
+	Settings     bool   `json:"settings"`
+}
+
+// DefaultSurveyClone copies everything from a survey as is
+func DefaultSurveyClone(source Survey) SurveyClone {
+	return SurveyClone{
+		Description: source.Description,
+		Members:     CloneMembersAll,
+		Elements:    true,
+		Order:       CloneOrderKeep,
+		Controls:    CloneControlsKeep,
+		Settings:    true,
+	}
+}
+
+func (sc SurveyClone) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if sc.Title == "" {
+		errs.add("title", "is required")
+	}
+	oneOf(&errs, "members", sc.Members, CloneMembersAll, CloneMembersOwners, CloneMembersNone)
+	oneOf(&errs, "order", sc.Order, CloneOrderKeep, CloneOrderRandom)
+	oneOf(&errs, "controls", sc.Controls, CloneControlsKeep, CloneControlsNone, CloneControlsRandom)
+	if sc.Controls == CloneControlsRandom && sc.ControlCount < 1 {
+		errs.add("controlCount", "must be at least 1 when controls are random")
+	}
+	return errs
+}
+
+func oneOf(errs *ValidationErrors, field string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	errs.add(field, "%s is not one of %s", value, strings.Join(allowed, ", "))
+}
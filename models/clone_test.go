@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyCloneValidation(t *testing.T) {
+	clone := DefaultSurveyClone(Survey{Description: "Orleans Parish"})
+	assert.Equal(t, []string{"title"}, fields(clone.Validate()))
+
+	clone.Title = "Jefferson Parish"
+	assert.Empty(t, clone.Validate())
+
+	clone.Members = "some"
+	clone.Controls = CloneControlsRandom
+	assert.Equal(t, []string{"members", "controlCount"}, fields(clone.Validate()))
+}
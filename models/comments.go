@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FlagDuplicate     = "duplicate"
+	FlagNotAStructure = "not_a_structure"
+	FlagWrongLocation = "wrong_location"
+	FlagNeedsReview   = "needs_review"
+)
+
+var FlagTypes = []DomainValue{
+	{FlagDuplicate, "Duplicate of another structure"},
+	{FlagNotAStructure, "Not a structure"},
+	{FlagWrongLocation, "Wrong location"},
+	{FlagNeedsReview, "Needs owner review"},
+}
+
+// Comment is a note on a survey element.  Replies reference the comment they answer through ParentID.
+type Comment struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	SurveyID  uuid.UUID  `db:"survey_id" json:"surveyId"`
+	SEID      uuid.UUID  `db:"se_id" json:"seId"`
+	SAID      *uuid.UUID `db:"sa_id" json:"saId"`
+	ParentID  *uuid.UUID `db:"parent_id" json:"parentId"`
+	UserID    string     `db:"user_id" json:"userId"`
+	UserName  string     `db:"user_name" json:"userName"`
+	Body      string     `db:"body" json:"body"`
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// Flag marks a survey element for owner attention until it is resolved
+type Flag struct {
+	ID           uuid.UUID  `db:"id" json:"id"`
+	SurveyID     uuid.UUID  `db:"survey_id" json:"surveyId"`
+	SEID         uuid.UUID  `db:"se_id" json:"seId"`
+	SAID         *uuid.UUID `db:"sa_id" json:"saId"`
+	FDID         int        `db:"fd_id" json:"fdId"`
+	FlagType     string     `db:"flag_type" json:"flagType"`
+	Note         string     `db:"note" json:"note"`
+	RaisedBy     string     `db:"raised_by" json:"raisedBy"`
+	RaisedByName string     `db:"raised_by_name" json:"raisedByName"`
+	RaisedAt     time.Time  `db:"raised_at" json:"raisedAt"`
+	Resolved     bool       `db:"resolved" json:"resolved"`
+	ResolvedBy   *string    `db:"resolved_by" json:"resolvedBy"`
+	ResolvedAt   *time.Time `db:"resolved_at" json:"resolvedAt"`
+	Resolution   string     `db:"resolution" json:"resolution"`
+}
+
+func ValidFlagType(flagType string) bool {
+	for _, ft := range FlagTypes {
+		if ft.Code == flagType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidFlagType(t *testing.T) {
+	for _, ft := range []string{FlagDuplicate, FlagNotAStructure, FlagWrongLocation, FlagNeedsReview} {
+		assert.True(t, ValidFlagType(ft), ft)
+	}
+	for _, ft := range []string{"", "Duplicate", "other"} {
+		assert.False(t, ValidFlagType(ft), ft)
+	}
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeInteger = "integer"
+	AttributeBoolean = "boolean"
+)
+
+// DomainValue is a single allowed value for a coded attribute
+type DomainValue struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// Attribute describes a survey structure attribute, the NSI column it maps to,
+// and the constraints a submitted value must satisfy
+type Attribute struct {
+	Column    string        `json:"column"`
+	Field     string        `json:"field"`
+	Label     string        `json:"label"`
+	Type      string        `json:"type"`
+	Unit      string        `json:"unit,omitempty"`
+	Required  bool          `json:"required"`
+	MaxLength int           `json:"maxLength,omitempty"`
+	Min       *float64      `json:"min,omitempty"`
+	Max       *float64      `json:"max,omitempty"`
+	Values    []DomainValue `json:"values,omitempty"`
+}
+
+func (a Attribute) Allows(code string) bool {
+	for _, v := range a.Values {
+		if v.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+var OccupancyTypes = []DomainValue{
+	{"RES1", "Single Family Dwelling"},
+	{"RES2", "Manufactured Housing"},
+	{"RES3A", "Multi Family Dwelling - Duplex"},
+	{"RES3B", "Multi Family Dwelling - 3-4 Units"},
+	{"RES3C", "Multi Family Dwelling - 5-9 Units"},
+	{"RES3D", "Multi Family Dwelling - 10-19 Units"},
+	{"RES3E", "Multi Family Dwelling - 20-49 Units"},
+	{"RES3F", "Multi Family Dwelling - 50+ Units"},
+	{"RES4", "Temporary Lodging"},
+	{"RES5", "Institutional Dormitory"},
+	{"RES6", "Nursing Home"},
+	{"COM1", "Retail Trade"},
+	{"COM2", "Wholesale Trade"},
+	{"COM3", "Personal and Repair Services"},
+	{"COM4", "Professional/Technical Services"},
+	{"COM5", "Banks"},
+	{"COM6", "Hospital"},
+	{"COM7", "Medical Office/Clinic"},
+	{"COM8", "Entertainment and Recreation"},
+	{"COM9", "Theaters"},
+	{"COM10", "Parking"},
+	{"IND1", "Heavy Industry"},
+	{"IND2", "Light Industry"},
+	{"IND3", "Food/Drugs/Chemicals"},
+	{"IND4", "Metals/Minerals Processing"},
+	{"IND5", "High Technology"},
+	{"IND6", "Construction"},
+	{"AGR1", "Agriculture"},
+	{"REL1", "Church/Membership Organization"},
+	{"GOV1", "General Services"},
+	{"GOV2", "Emergency Response"},
+	{"EDU1", "Grade Schools"},
+	{"EDU2", "Colleges/Universities"},
+}
+
+var DamageCategories = []DomainValue{
+	{"RES", "Residential"},
+	{"COM", "Commercial"},
+	{"IND", "Industrial"},
+	{"PUB", "Public"},
+}
+
+var FoundationTypes = []DomainValue{
+	{"B", "Basement"},
+	{"C", "Crawl"},
+	{"F", "Fill"},
+	{"I", "Pile"},
+	{"P", "Pier"},
+	{"S", "Slab"},
+	{"W", "Solid Wall"},
+}
+
+var ConstructionTypes = []DomainValue{
+	{"W", "Wood"},
+	{"M", "Masonry"},
+	{"C", "Concrete"},
+	{"S", "Steel"},
+	{"H", "Manufactured"},
+}
+
+var QualityTypes = []DomainValue{
+	{"Economy", "Economy"},
+	{"Average", "Average"},
+	{"Custom", "Custom"},
+	{"Luxury", "Luxury"},
+}
+
+var GarageTypes = []DomainValue{
+	{"None", "No garage"},
+	{"Attached", "Attached garage"},
+	{"Detached", "Detached garage"},
+	{"BuiltIn", "Built-in garage"},
+	{"Carport", "Carport"},
+}
+
+var RoofStyles = []DomainValue{
+	{"Gable", "Gable"},
+	{"Hip", "Hip"},
+	{"Flat", "Flat"},
+	{"Gambrel", "Gambrel"},
+	{"Mansard", "Mansard"},
+	{"Shed", "Shed"},
+}
+
+// StructureAttributes is the default attribute set for a survey structure.  Column
+// lengths mirror the survey_result table definition.
+var StructureAttributes = []Attribute{
+	{Column: "fd_id", Field: "fdId", Label: "NSI Structure Id", Type: AttributeInteger, Required: true, Min: bound(1)},
+	{Column: "x", Field: "x", Label: "Longitude", Type: AttributeNumber, Unit: "decimal degrees", Required: true, Min: bound(-180), Max: bound(180)},
+	{Column: "y", Field: "y", Label: "Latitude", Type: AttributeNumber, Unit: "decimal degrees", Required: true, Min: bound(-90), Max: bound(90)},
+	{Column: "invalid_structure", Field: "invalidStructure", Label: "Invalid Structure", Type: AttributeBoolean},
+	{Column: "no_street_view", Field: "noStreetView", Label: "No Street View", Type: AttributeBoolean},
+	{Column: "cbfips", Field: "cbfips", Label: "Census Block FIPS", Type: AttributeString, MaxLength: 15},
+	{Column: "occtype", Field: "occupancyType", Label: "Occupancy Type", Type: AttributeString, Required: true, MaxLength: 9, Values: OccupancyTypes},
+	{Column: "st_damcat", Field: "damcat", Label: "Damage Category", Type: AttributeString, Required: true, MaxLength: 3, Values: DamageCategories},
+	{Column: "found_ht", Field: "found_ht", Label: "Foundation Height", Type: AttributeNumber, Unit: "ft", Min: bound(0), Max: bound(50)},
+	{Column: "num_story", Field: "stories", Label: "Number of Stories", Type: AttributeNumber, Min: bound(0), Max: bound(150)},
+	{Column: "sqft", Field: "sq_ft", Label: "Square Footage", Type: AttributeNumber, Unit: "sq ft", Min: bound(0), Max: bound(5000000)},
+	{Column: "found_type", Field: "found_type", Label: "Foundation Type", Type: AttributeString, Required: true, MaxLength: 4, Values: FoundationTypes},
+	{Column: "rsmeans_type", Field: "rsmeans_type", Label: "RSMeans Type", Type: AttributeString, MaxLength: 50},
+	{Column: "quality", Field: "quality", Label: "Construction Quality", Type: AttributeString, MaxLength: 50, Values: QualityTypes},
+	{Column: "const_type", Field: "const_type", Label: "Construction Type", Type: AttributeString, MaxLength: 50, Values: ConstructionTypes},
+	{Column: "garage", Field: "garage", Label: "Garage", Type: AttributeString, MaxLength: 50, Values: GarageTypes},
+	{Column: "roof_style", Field: "roof_style", Label: "Roof Style", Type: AttributeString, MaxLength: 50, Values: RoofStyles},
+}
+
+// SurveyDomainValue is a survey specific allowed value that replaces the default
+// domain of a coded attribute
+type SurveyDomainValue struct {
+	SurveyID    uuid.UUID `db:"survey_id" json:"surveyId"`
+	Column      string    `db:"column_name" json:"column"`
+	Code        string    `db:"code" json:"code"`
+	Description string    `db:"description" json:"description"`
+}
+
+// ApplyDomainOverrides returns a copy of the attribute set with the allowed values of
+// any attribute that has survey specific values replaced
+func ApplyDomainOverrides(attributes []Attribute, overrides []SurveyDomainValue) []Attribute {
+	values := map[string][]DomainValue{}
+	for _, o := range overrides {
+		values[o.Column] = append(values[o.Column], DomainValue{o.Code, o.Description})
+	}
+	attrs := make([]Attribute, len(attributes))
+	for i, attr := range attributes {
+		if v, ok := values[attr.Column]; ok {
+			attr.Values = v
+		}
+		attrs[i] = attr
+	}
+	return attrs
+}
+
+// FindAttribute returns the attribute mapped to an NSI column name
+func FindAttribute(attributes []Attribute, column string) (Attribute, bool) {
+	for _, attr := range attributes {
+		if attr.Column == column {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// DamcatForOccupancy returns the damage category implied by an occupancy type
+// prefix, or an empty string if the prefix is not recognized
+func DamcatForOccupancy(occtype string) string {
+	switch {
+	case strings.HasPrefix(occtype, "RES"):
+		return "RES"
+	case strings.HasPrefix(occtype, "COM"):
+		return "COM"
+	case strings.HasPrefix(occtype, "IND"), strings.HasPrefix(occtype, "AGR"):
+		return "IND"
+	case strings.HasPrefix(occtype, "REL"), strings.HasPrefix(occtype, "GOV"), strings.HasPrefix(occtype, "EDU"):
+		return "PUB"
+	}
+	return ""
+}
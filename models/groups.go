@@ -0,0 +1,30 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SurveyGroup binds an identity provider group to a survey.  Users whose token roles include the group
+// are made members of the survey with the group's role when they authenticate.
+type SurveyGroup struct {
+	SurveyID uuid.UUID `db:"survey_id" json:"surveyId"`
+	Group    string    `db:"group_name" json:"group"`
+	Role     string    `db:"role" json:"role"`
+	Members  int       `db:"members" json:"members"`
+}
+
+func (g SurveyGroup) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if strings.TrimSpace(g.Group) == "" {
+		errs.add("group", "is required")
+	}
+	if len(g.Group) > 100 {
+		errs.add("group", "must be 100 characters or less")
+	}
+	if !ValidRole(g.Role) {
+		errs.add("role", "%s is not a valid role", g.Role)
+	}
+	return errs
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSurveyGroup(t *testing.T) {
+	assert.Empty(t, SurveyGroup{Group: "nsi-field-crew", Role: RoleSurveyor}.Validate())
+	assert.Equal(t, []string{"group", "role"}, fields(SurveyGroup{Group: " "}.Validate()))
+	assert.Equal(t, []string{"group"}, fields(SurveyGroup{Group: strings.Repeat("g", 101), Role: RoleViewer}.Validate()))
+}
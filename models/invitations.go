@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation states.  Expired is not stored; it is reported for pending invitations past their expiry.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationExpired  = "expired"
+	InvitationRevoked  = "revoked"
+)
+
+const (
+	DefaultInvitationDays = 14
+	MaxInvitationDays     = 90
+)
+
+// Invitation grants survey membership to a user that may not have logged in yet.  The invitation is
+// accepted the first time a user whose token carries the matching subject or email authenticates.
+// Token emails are not necessarily verified by the identity provider, so owner and coordinator
+// invitations must name the subject and are only accepted by it.
+type Invitation struct {
+	ID            uuid.UUID  `db:"id" json:"id"`
+	SurveyID      uuid.UUID  `db:"survey_id" json:"surveyId"`
+	Email         string     `db:"email" json:"email"`
+	Subject       string     `db:"subject" json:"subject"`
+	Role          string     `db:"role" json:"role"`
+	Status        string     `db:"status" json:"status"`
+	InvitedBy     string     `db:"invited_by" json:"invitedBy"`
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+	ExpiresAt     time.Time  `db:"expires_at" json:"expiresAt"`
+	AcceptedBy    string     `db:"accepted_by" json:"acceptedBy"`
+	AcceptedAt    *time.Time `db:"accepted_at" json:"acceptedAt"`
+	ExpiresInDays int        `db:"-" json:"expiresInDays,omitempty"`
+}
+
+// Validate checks a new invitation.  An empty role invites a surveyor and zero days uses the default expiry.
+func (i Invitation) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if i.Email == "" && i.Subject == "" {
+		errs.add("email", "an email or subject is required")
+	}
+	if i.Email != "" && !validEmail(i.Email) {
+		errs.add("email", "%s is not a valid email address", i.Email)
+	}
+	if len(i.Email) > 255 {
+		errs.add("email", "must be 255 characters or less")
+	}
+	if len(i.Subject) > 50 {
+		errs.add("subject", "must be 50 characters or less")
+	}
+	if i.Role != "" && !ValidRole(i.Role) {
+		errs.add("role", "%s is not a valid role", i.Role)
+	}
+	if i.Subject == "" && RequiresSubject(i.Role) {
+		errs.add("subject", "is required for %s invitations", i.Role)
+	}
+	if i.ExpiresInDays < 0 || i.ExpiresInDays > MaxInvitationDays {
+		errs.add("expiresInDays", "must be between 0 and %d", MaxInvitationDays)
+	}
+	return errs
+}
+
+// RequiresSubject reports whether invitations for a role may only be accepted by their subject
+func RequiresSubject(role string) bool {
+	return role == RoleOwner || role == RoleCoordinator
+}
+
+func validEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	return at > 0 && at < len(email)-1 && !strings.ContainsAny(email, " \t<>,;") && strings.Contains(email[at:], ".")
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitationIdentity(t *testing.T) {
+	assert.Equal(t, []string{"email"}, fields(Invitation{}.Validate()))
+	assert.Empty(t, Invitation{Email: "crew.lead@example.com"}.Validate())
+	assert.Empty(t, Invitation{Subject: "987654"}.Validate())
+}
+
+func TestInvitationEmail(t *testing.T) {
+	for _, email := range []string{"crew.lead", "@example.com", "crew.lead@", "crew lead@example.com", "crew.lead@localhost"} {
+		assert.Equal(t, []string{"email"}, fields(Invitation{Email: email}.Validate()), email)
+	}
+}
+
+func TestInvitationRoleAndExpiry(t *testing.T) {
+	i := Invitation{Subject: "987654", Role: "admin", ExpiresInDays: MaxInvitationDays + 1}
+	assert.Equal(t, []string{"role", "expiresInDays"}, fields(i.Validate()))
+	i.Role = RoleViewer
+	i.ExpiresInDays = MaxInvitationDays
+	assert.Empty(t, i.Validate())
+}
+
+func TestPrivilegedInvitationsRequireSubject(t *testing.T) {
+	for _, role := range []string{RoleOwner, RoleCoordinator} {
+		assert.Equal(t, []string{"subject"}, fields(Invitation{Email: "crew.lead@example.com", Role: role}.Validate()), role)
+		assert.Empty(t, Invitation{Email: "crew.lead@example.com", Subject: "987654", Role: role}.Validate(), role)
+	}
+	for _, role := range []string{"", RoleReviewer, RoleSurveyor, RoleViewer} {
+		assert.Empty(t, Invitation{Email: "crew.lead@example.com", Role: role}.Validate(), role)
+	}
+}
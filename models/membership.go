@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// What happens to a removed member's open assignments
+const (
+	ReleaseAssignments  = "release"
+	ReassignAssignments = "reassign"
+	KeepAssignments     = "keep"
+)
+
+// MemberRemoval describes how a member's open assignments are settled when they are removed from a survey.
+// Released assignments are deleted along with their draft results and attachments so the elements can be
+// issued again; their comments and flags stay on the element.  Reassigned assignments move to ReassignTo,
+// except controls that member already holds, which are released.  Kept assignments stay with the user.
+type MemberRemoval struct {
+	Assignments string `json:"assignments"`
+	ReassignTo  string `json:"reassignTo"`
+}
+
+// Validate checks a removal for a member with open assignments.  Members without open assignments may be
+// removed without choosing what happens to them.
+func (r MemberRemoval) Validate(userId string, open int) ValidationErrors {
+	errs := ValidationErrors{}
+	switch r.Assignments {
+	case "":
+		if open > 0 {
+			errs.add("assignments", "member has %d open assignments; one of %s, %s, or %s is required", open,
+				ReleaseAssignments, ReassignAssignments, KeepAssignments)
+		}
+	case ReleaseAssignments, KeepAssignments:
+	case ReassignAssignments:
+		if r.ReassignTo == "" {
+			errs.add("reassignTo", "is required to reassign assignments")
+		} else if r.ReassignTo == userId {
+			errs.add("reassignTo", "must be a different member")
+		}
+	default:
+		errs.add("assignments", "%s is not one of %s, %s, or %s", r.Assignments,
+			ReleaseAssignments, ReassignAssignments, KeepAssignments)
+	}
+	return errs
+}
+
+// MemberRemovalResult counts how a removed member's open assignments were settled
+type MemberRemovalResult struct {
+	Released    int          `json:"released"`
+	Reassigned  int          `json:"reassigned"`
+	Kept        int          `json:"kept"`
+	Attachments []Attachment `json:"-"`
+}
+
+// Member import actions
+const (
+	ImportAdd    = "add"
+	ImportUpdate = "update"
+	ImportInvite = "invite"
+)
+
+// MemberImportRow is one record of a member import.  User is either the id of an existing user or an
+// email address, which is invited.
+type MemberImportRow struct {
+	Row    int    `json:"row"`
+	User   string `json:"user"`
+	Role   string `json:"role"`
+	Action string `json:"action"`
+}
+
+// MemberImportResult reports what a member import did, or would do for a dry run
+type MemberImportResult struct {
+	Result  string            `json:"result"`
+	Added   int               `json:"added"`
+	Updated int               `json:"updated"`
+	Invited int               `json:"invited"`
+	Rows    []MemberImportRow `json:"rows"`
+}
+
+// ParseMemberImport reads member import CSV records of user id or email and an optional role, which
+// defaults to surveyor.  A leading header row is skipped.  Rows are numbered by record, counting the
+// header and ignoring blank lines.
+func ParseMemberImport(r io.Reader) ([]MemberImportRow, ValidationErrors) {
+	errs := ValidationErrors{}
+	rows := []MemberImportRow{}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs.add(fmt.Sprintf("row[%d]", line), "%s", err)
+			return rows, errs
+		}
+		if len(record) > 2 {
+			errs.add(fmt.Sprintf("row[%d]", line), "expected a user and a role but found %d columns", len(record))
+			continue
+		}
+		row := MemberImportRow{Row: line, User: strings.TrimSpace(record[0]), Role: RoleSurveyor}
+		if len(record) == 2 && strings.TrimSpace(record[1]) != "" {
+			row.Role = strings.ToLower(strings.TrimSpace(record[1]))
+		}
+		if line == 1 && isImportHeader(row.User) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 && len(errs) == 0 {
+		errs.add("file", "contains no members")
+	}
+	return rows, errs
+}
+
+func isImportHeader(user string) bool {
+	switch strings.ToLower(user) {
+	case "user", "userid", "user_id", "email":
+		return true
+	}
+	return false
+}
+
+// PlanMemberImport validates import rows against the survey and decides the action for each row.  known
+// holds the user ids that exist, members maps current member user ids to their role, and invited holds
+// the lower case emails with a pending invitation.  Rows granting the owner role or changing an owner
+// are refused unless manageOwners is set, as are imports that would leave a survey with owners without one.
+func PlanMemberImport(rows []MemberImportRow, known map[string]bool, members map[string]string, invited map[string]bool, manageOwners bool) ValidationErrors {
+	errs := ValidationErrors{}
+	seen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		field := fmt.Sprintf("row[%d]", row.Row)
+		key := strings.ToLower(row.User)
+		switch {
+		case row.User == "":
+			errs.add(field+".user", "a user id or email is required")
+			continue
+		case seen[key] > 0:
+			errs.add(field+".user", "%s is repeated from row %d", row.User, seen[key])
+			continue
+		}
+		seen[key] = row.Row
+		if !ValidRole(row.Role) {
+			errs.add(field+".role", "%s is not a valid role", row.Role)
+		}
+		if strings.Contains(row.User, "@") {
+			switch {
+			case !validEmail(row.User):
+				errs.add(field+".user", "%s is not a valid email address", row.User)
+			case invited[key]:
+				errs.add(field+".user", "%s already has a pending invitation", row.User)
+			}
+			row.Action = ImportInvite
+		} else if !known[row.User] {
+			errs.add(field+".user", "%s is not a known user; invite them by email", row.User)
+		} else if role, ok := members[row.User]; ok {
+			row.Action = ImportUpdate
+			if role == RoleOwner && row.Role != RoleOwner && !manageOwners {
+				errs.add(field+".role", "only owners may change the role of an owner")
+			}
+		} else {
+			row.Action = ImportAdd
+		}
+		if row.Role == RoleOwner && !manageOwners {
+			errs.add(field+".role", "only owners may grant the owner role")
+		}
+	}
+	if owners(members) > 0 {
+		planned := map[string]string{}
+		for user, role := range members {
+			planned[user] = role
+		}
+		for _, row := range rows {
+			if row.Action == ImportAdd || row.Action == ImportUpdate {
+				planned[row.User] = row.Role
+			}
+		}
+		if owners(planned) == 0 {
+			errs.add("members", "the import would leave the survey without an owner")
+		}
+	}
+	return errs
+}
+
+func owners(members map[string]string) int {
+	n := 0
+	for _, role := range members {
+		if role == RoleOwner {
+			n++
+		}
+	}
+	return n
+}
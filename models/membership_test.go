@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberRemovalWithoutOpenAssignments(t *testing.T) {
+	assert.Empty(t, MemberRemoval{}.Validate("123", 0))
+	assert.Equal(t, []string{"assignments"}, fields(MemberRemoval{}.Validate("123", 2)))
+}
+
+func TestMemberRemovalActions(t *testing.T) {
+	assert.Empty(t, MemberRemoval{Assignments: ReleaseAssignments}.Validate("123", 2))
+	assert.Empty(t, MemberRemoval{Assignments: KeepAssignments}.Validate("123", 2))
+	assert.Empty(t, MemberRemoval{Assignments: ReassignAssignments, ReassignTo: "456"}.Validate("123", 2))
+	assert.Equal(t, []string{"assignments"}, fields(MemberRemoval{Assignments: "drop"}.Validate("123", 2)))
+}
+
+func TestMemberRemovalReassignTarget(t *testing.T) {
+	assert.Equal(t, []string{"reassignTo"}, fields(MemberRemoval{Assignments: ReassignAssignments}.Validate("123", 2)))
+	assert.Equal(t, []string{"reassignTo"}, fields(MemberRemoval{Assignments: ReassignAssignments, ReassignTo: "123"}.Validate("123", 2)))
+}
+
+func TestParseMemberImport(t *testing.T) {
+	rows, errs := ParseMemberImport(strings.NewReader("user,role\n987654,Coordinator\n\ncrew.lead@example.com\n"))
+	assert.Empty(t, errs)
+	assert.Equal(t, []MemberImportRow{
+		{Row: 2, User: "987654", Role: RoleCoordinator},
+		{Row: 3, User: "crew.lead@example.com", Role: RoleSurveyor},
+	}, rows)
+	_, errs = ParseMemberImport(strings.NewReader("user,role\n"))
+	assert.Equal(t, []string{"file"}, fields(errs))
+	_, errs = ParseMemberImport(strings.NewReader("987654,viewer,extra\n"))
+	assert.Equal(t, []string{"row[1]"}, fields(errs))
+}
+
+func TestPlanMemberImport(t *testing.T) {
+	rows := []MemberImportRow{
+		{Row: 1, User: "987654", Role: RoleViewer},
+		{Row: 2, User: "987655", Role: RoleSurveyor},
+		{Row: 3, User: "crew.lead@example.com", Role: RoleSurveyor},
+	}
+	known := map[string]bool{"987654": true, "987655": true}
+	members := map[string]string{"987655": RoleReviewer}
+	assert.Empty(t, PlanMemberImport(rows, known, members, map[string]bool{}, false))
+	assert.Equal(t, []string{ImportAdd, ImportUpdate, ImportInvite}, []string{rows[0].Action, rows[1].Action, rows[2].Action})
+}
+
+func TestPlanMemberImportErrors(t *testing.T) {
+	rows := []MemberImportRow{
+		{Row: 1, User: "unknown", Role: RoleSurveyor},
+		{Row: 2, User: "987654", Role: RoleOwner},
+		{Row: 3, User: "987655", Role: RoleViewer},
+		{Row: 4, User: "Crew.Lead@example.com", Role: "lead"},
+		{Row: 5, User: "987654", Role: RoleViewer},
+	}
+	known := map[string]bool{"987654": true, "987655": true}
+	members := map[string]string{"987655": RoleOwner}
+	invited := map[string]bool{"crew.lead@example.com": true}
+	assert.Equal(t, []string{"row[1].user", "row[2].role", "row[3].role", "row[4].role", "row[4].user", "row[5].user"},
+		fields(PlanMemberImport(rows, known, members, invited, false)))
+}
+
+func TestPlanMemberImportKeepsAnOwner(t *testing.T) {
+	known := map[string]bool{"987654": true, "987655": true}
+	members := map[string]string{"987654": RoleOwner, "987655": RoleOwner}
+	rows := []MemberImportRow{{Row: 1, User: "987654", Role: RoleViewer}, {Row: 2, User: "987655", Role: RoleCoordinator}}
+	assert.Equal(t, []string{"members"}, fields(PlanMemberImport(rows, known, members, map[string]bool{}, true)))
+	rows = []MemberImportRow{{Row: 1, User: "987654", Role: RoleViewer}}
+	assert.Empty(t, PlanMemberImport(rows, known, members, map[string]bool{}, true))
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,10 +14,12 @@ type JwtClaim struct {
 }
 
 type Survey struct {
-	ID          uuid.UUID `db:"id" json:"id"`
-	Title       string    `db:"title" json:"title"`
-	Description string    `db:"description" json:"description"`
-	Active      bool      `db:"active" json:"active"`
+	ID          uuid.UUID  `db:"id" json:"id"`
+	Title       string     `db:"title" json:"title"`
+	Description string     `db:"description" json:"description"`
+	Active      bool       `db:"active" json:"active"`
+	Archived    bool       `db:"archived" json:"archived"`
+	ArchivedAt  *time.Time `db:"archived_at" json:"archivedAt,omitempty"`
 }
 
 type User struct {
@@ -24,11 +27,14 @@ type User struct {
 	Username string `db:"user_name" json:"userName"`
 }
 
+// SurveyMember is a user's membership in a survey.  IsOwner is kept for older clients and is
+// true exactly when the role is owner.
 type SurveyMember struct {
 	ID       uuid.UUID `db:"id" json:"id"`
 	SurveyID uuid.UUID `db:"survey_id" json:"surveyId"`
 	UserID   string    `db:"user_id" json:"userId"`
 	IsOwner  bool      `db:"is_owner" json:"isOwner"`
+	Role     string    `db:"role" json:"role"`
 }
 
 // used in GetSurveyMembers handler
@@ -37,17 +43,8 @@ type SurveyMemberAlt struct {
 	UserID   string    `db:"user_id" json:"userId"`
 	UserName string    `db:"user_name" json:"userName"`
 	IsOwner  bool      `db:"is_owner" json:"isOwner"`
-}
-
-type AssignmentInfo struct {
-	SAID             *uuid.UUID `db:"sa_id"` // survey_assignment id
-	SEID             *uuid.UUID `db:"se_id"` // survey_element id
-	Completed        *bool      `db:"completed"`
-	SurveyOrder      *int       `db:"survey_order"`
-	NextSurveyOrder  *int       `db:"next_survey_order"`
-	NextSurveySEID   *uuid.UUID `db:"next_survey_seid"`
-	NextControlOrder *int       `db:"next_control_order"`
-	NextControlSEID  *uuid.UUID `db:"next_control_seid"`
+	Role     string    `db:"role" json:"role"`
+	Group    string    `db:"group_name" json:"group"`
 }
 
 type SurveyElement struct {
@@ -72,6 +69,17 @@ type SurveyAssignment struct {
 	Assigned         string    `json:"assignedTo" db:"assigned_to"`
 }
 
+// MemberAssignment summarizes an assignment in a member's work history
+type MemberAssignment struct {
+	SAID        uuid.UUID `db:"sa_id" json:"saId"`
+	SEID        uuid.UUID `db:"se_id" json:"seId"`
+	FDID        int       `db:"fd_id" json:"fdId"`
+	SurveyOrder int       `db:"survey_order" json:"surveyOrder"`
+	IsControl   bool      `db:"is_control" json:"isControl"`
+	Completed   bool      `db:"completed" json:"completed"`
+	Draft       bool      `db:"draft" json:"draft"`
+}
+
 type SurveyStructure struct {
 	SAID             uuid.UUID `db:"sa_id" json:"saId"`
 	FDID             int       `db:"fd_id" json:"fdId"`
@@ -91,6 +99,8 @@ type SurveyStructure struct {
 	ConstType        string    `db:"const_type" json:"const_type"`
 	Garage           string    `db:"garage" json:"garage"`
 	RoofStyle        string    `db:"roof_style" json:"roof_style"`
+	Draft            bool      `db:"draft" json:"draft"`
+	Version          int       `db:"version" json:"version"`
 }
 
 type SurveyResult struct {
@@ -130,3 +140,30 @@ func (sr SurveyResult) String() []string {
 		fmt.Sprintf(`"%s"`, sr.RoofStyle),
 	})
 }
+
+const (
+	SyncSubmitted = "submitted"
+	SyncDraft     = "draft"
+	SyncConflict  = "conflict"
+	SyncInvalid   = "invalid"
+	SyncNotFound  = "not_found"
+	SyncError     = "error"
+)
+
+// SyncResult reports the outcome of a single result in a bulk sync
+type SyncResult struct {
+	SAID    uuid.UUID        `json:"saId"`
+	Status  string           `json:"status"`
+	Version int              `json:"version,omitempty"`
+	Message string           `json:"message,omitempty"`
+	Errors  ValidationErrors `json:"errors,omitempty"`
+}
+
+// IdempotentResponse is the stored response for a request made with an Idempotency-Key header
+type IdempotentResponse struct {
+	RequestHash string `db:"request_hash"`
+	StatusCode  int    `db:"status_code"`
+	Response    string `db:"response"`
+	ContentType string `db:"content_type"`
+	ETag        string `db:"etag"`
+}
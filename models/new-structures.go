@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NewStructurePending  = "pending"
+	NewStructureApproved = "approved"
+	NewStructureRejected = "rejected"
+)
+
+// NewStructure is a structure found in the field that is missing from the NSI.  It is identified by a
+// provisional id until an owner reviews it.
+type NewStructure struct {
+	ID              uuid.UUID  `db:"id" json:"id"`
+	SurveyID        uuid.UUID  `db:"survey_id" json:"surveyId"`
+	ProvisionalID   string     `db:"provisional_id" json:"provisionalId"`
+	SubmittedBy     string     `db:"submitted_by" json:"submittedBy"`
+	SubmittedByName string     `db:"submitted_by_name" json:"submittedByName"`
+	SubmittedAt     time.Time  `db:"submitted_at" json:"submittedAt"`
+	Status          string     `db:"status" json:"status"`
+	ReviewedBy      *string    `db:"reviewed_by" json:"reviewedBy"`
+	ReviewedAt      *time.Time `db:"reviewed_at" json:"reviewedAt"`
+	ReviewNote      string     `db:"review_note" json:"reviewNote"`
+	X               float64    `db:"x" json:"x"`
+	Y               float64    `db:"y" json:"y"`
+	CBfips          string     `db:"cbfips" json:"cbfips"`
+	OccupancyType   string     `db:"occtype" json:"occupancyType"`
+	Damcat          string     `db:"st_damcat" json:"damcat"`
+	FoundHt         float64    `db:"found_ht" json:"found_ht"`
+	Stories         float64    `db:"num_story" json:"stories"`
+	SqFt            float64    `db:"sqft" json:"sq_ft"`
+	FoundType       string     `db:"found_type" json:"found_type"`
+	RsmeansType     string     `db:"rsmeans_type" json:"rsmeans_type"`
+	Quality         string     `db:"quality" json:"quality"`
+	ConstType       string     `db:"const_type" json:"const_type"`
+	Garage          string     `db:"garage" json:"garage"`
+	RoofStyle       string     `db:"roof_style" json:"roof_style"`
+}
+
+// SurveyStructure returns the attribute values of the new structure as a survey structure
+func (ns NewStructure) SurveyStructure() SurveyStructure {
+	return SurveyStructure{
+		X:             ns.X,
+		Y:             ns.Y,
+		CBfips:        ns.CBfips,
+		OccupancyType: ns.OccupancyType,
+		Damcat:        ns.Damcat,
+		FoundHt:       ns.FoundHt,
+		Stories:       ns.Stories,
+		SqFt:          ns.SqFt,
+		FoundType:     ns.FoundType,
+		RsmeansType:   ns.RsmeansType,
+		Quality:       ns.Quality,
+		ConstType:     ns.ConstType,
+		Garage:        ns.Garage,
+		RoofStyle:     ns.RoofStyle,
+	}
+}
+
+func (ns NewStructure) String() []string {
+	reviewedBy := ""
+	if ns.ReviewedBy != nil {
+		reviewedBy = *ns.ReviewedBy
+	}
+	return ([]string{
+		fmt.Sprintf(`"%s"`, ns.ProvisionalID),
+		fmt.Sprintf(`"%s"`, ns.Status),
+		fmt.Sprintf(`"%s"`, ns.SubmittedBy),
+		fmt.Sprintf(`"%s"`, ns.SubmittedByName),
+		ns.SubmittedAt.Format(time.RFC3339),
+		fmt.Sprintf(`"%s"`, reviewedBy),
+		strconv.FormatFloat(ns.X, 'f', 8, 64),
+		strconv.FormatFloat(ns.Y, 'f', 8, 64),
+		fmt.Sprintf(`"%s"`, ns.CBfips),
+		fmt.Sprintf(`"%s"`, ns.OccupancyType),
+		fmt.Sprintf(`"%s"`, ns.Damcat),
+		strconv.FormatFloat(ns.FoundHt, 'f', 4, 64),
+		strconv.FormatFloat(ns.Stories, 'f', 4, 64),
+		strconv.FormatFloat(ns.SqFt, 'f', 4, 64),
+		fmt.Sprintf(`"%s"`, ns.FoundType),
+		fmt.Sprintf(`"%s"`, ns.RsmeansType),
+		fmt.Sprintf(`"%s"`, ns.Quality),
+		fmt.Sprintf(`"%s"`, ns.ConstType),
+		fmt.Sprintf(`"%s"`, ns.Garage),
+		fmt.Sprintf(`"%s"`, ns.RoofStyle),
+	})
+}
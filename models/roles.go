@@ -0,0 +1,47 @@
+package models
+
+// Survey member roles, from most to least privileged
+const (
+	RoleOwner       = "owner"
+	RoleCoordinator = "coordinator"
+	RoleReviewer    = "reviewer"
+	RoleSurveyor    = "surveyor"
+	RoleViewer      = "viewer"
+)
+
+var MemberRoles = []DomainValue{
+	{RoleOwner, "Manages the survey, its settings, and its members"},
+	{RoleCoordinator, "Manages members, territories, and assignments and reads progress"},
+	{RoleReviewer, "Reviews flags, comments, and new structures and reads progress"},
+	{RoleSurveyor, "Draws and submits assignments"},
+	{RoleViewer, "Reads progress and reports"},
+}
+
+func ValidRole(role string) bool {
+	for _, r := range MemberRoles {
+		if r.Code == role {
+			return true
+		}
+	}
+	return false
+}
+
+// MemberRole returns the role of a member, falling back to IsOwner for requests that do not set a role
+func (m SurveyMember) MemberRole() string {
+	switch {
+	case m.Role != "":
+		return m.Role
+	case m.IsOwner:
+		return RoleOwner
+	}
+	return RoleSurveyor
+}
+
+// RoleCacheStats reports the effectiveness of the in process cache of survey member roles
+type RoleCacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+	Entries int     `json:"entries"`
+	TTL     string  `json:"ttl"`
+}
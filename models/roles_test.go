@@ -0,0 +1,19 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberRole(t *testing.T) {
+	assert.Equal(t, RoleSurveyor, SurveyMember{}.MemberRole())
+	assert.Equal(t, RoleOwner, SurveyMember{IsOwner: true}.MemberRole())
+	assert.Equal(t, RoleViewer, SurveyMember{IsOwner: true, Role: RoleViewer}.MemberRole())
+}
+
+func TestValidRole(t *testing.T) {
+	assert.True(t, ValidRole(RoleReviewer))
+	assert.False(t, ValidRole("admin"))
+	assert.False(t, ValidRole(""))
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key permissions.  Each permission opens a fixed set of routes to keys that carry it.
+const (
+	PermElementsRead     = "elements:read"
+	PermElementsWrite    = "elements:write"
+	PermAssignmentsWrite = "assignments:write"
+	PermReportRead       = "report:read"
+)
+
+var APIKeyPermissions = []DomainValue{
+	{PermElementsRead, "Lists the elements of a survey"},
+	{PermElementsWrite, "Loads elements into a survey"},
+	{PermAssignmentsWrite, "Assigns elements to survey members"},
+	{PermReportRead, "Reads the survey and new structure reports"},
+}
+
+// API keys are issued as nsis_<prefix>_<secret>.  The prefix identifies the key and is stored in the clear;
+// only a SHA-256 hash of the whole key is stored.
+const (
+	apiKeyScheme  = "nsis"
+	MaxAPIKeyDays = 365
+)
+
+// Service account ids are prefixed so they cannot be confused with identity provider subjects
+const ServiceSubjectPrefix = "service:"
+
+func ValidPermission(permission string) bool {
+	for _, p := range APIKeyPermissions {
+		if p.Code == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceAccount is a non human caller, such as an ETL job, that authenticates with API keys
+type ServiceAccount struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	Active      bool      `db:"active" json:"active"`
+	CreatedBy   string    `db:"created_by" json:"createdBy"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+	ActiveKeys  int       `db:"active_keys" json:"activeKeys"`
+}
+
+func (a ServiceAccount) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if strings.TrimSpace(a.Name) == "" {
+		errs.add("name", "is required")
+	}
+	if len(a.Name) > 100 {
+		errs.add("name", "must be 100 characters or less")
+	}
+	if len(a.Description) > 1000 {
+		errs.add("description", "must be 1000 characters or less")
+	}
+	return errs
+}
+
+// ServiceSubject is the user id recorded for requests made with the keys of a service account
+func ServiceSubject(accountId uuid.UUID) string {
+	return ServiceSubjectPrefix + accountId.String()
+}
+
+// APIKey grants a service account the listed permissions on the listed surveys.  Keys without an expiry
+// remain valid until they are revoked.
+type APIKey struct {
+	ID             uuid.UUID   `db:"id" json:"id"`
+	AccountID      uuid.UUID   `db:"account_id" json:"accountId"`
+	Name           string      `db:"name" json:"name"`
+	Prefix         string      `db:"prefix" json:"prefix"`
+	PermissionList string      `db:"permissions" json:"-"`
+	Permissions    []string    `db:"-" json:"permissions"`
+	SurveyList     string      `db:"surveys" json:"-"`
+	Surveys        []uuid.UUID `db:"-" json:"surveys"`
+	CreatedBy      string      `db:"created_by" json:"createdBy"`
+	CreatedAt      time.Time   `db:"created_at" json:"createdAt"`
+	ExpiresAt      *time.Time  `db:"expires_at" json:"expiresAt"`
+	LastUsedAt     *time.Time  `db:"last_used_at" json:"lastUsedAt"`
+	RevokedAt      *time.Time  `db:"revoked_at" json:"revokedAt"`
+	ExpiresInDays  int         `db:"-" json:"expiresInDays,omitempty"`
+}
+
+// IssuedAPIKey is returned once when a key is created.  The key itself cannot be retrieved again.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// Validate checks a new key and drops repeated surveys.  Zero days issues a key that does not expire.
+func (k *APIKey) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	seen := map[uuid.UUID]bool{}
+	surveys := []uuid.UUID{}
+	for _, s := range k.Surveys {
+		if !seen[s] {
+			seen[s] = true
+			surveys = append(surveys, s)
+		}
+	}
+	k.Surveys = surveys
+	if len(k.Name) > 100 {
+		errs.add("name", "must be 100 characters or less")
+	}
+	if len(k.Permissions) == 0 {
+		errs.add("permissions", "at least one permission is required")
+	}
+	for i, p := range k.Permissions {
+		if !ValidPermission(p) {
+			errs.add(fmt.Sprintf("permissions[%d]", i), "%s is not a valid permission", p)
+		}
+	}
+	if len(k.Surveys) == 0 {
+		errs.add("surveys", "at least one survey is required")
+	}
+	if k.ExpiresInDays < 0 || k.ExpiresInDays > MaxAPIKeyDays {
+		errs.add("expiresInDays", "must be between 0 and %d", MaxAPIKeyDays)
+	}
+	return errs
+}
+
+// Expand fills the permissions and surveys of a key from their stored text forms
+func (k *APIKey) Expand() error {
+	k.Permissions = []string{}
+	if k.PermissionList != "" {
+		k.Permissions = strings.Split(k.PermissionList, ",")
+	}
+	k.Surveys = []uuid.UUID{}
+	if k.SurveyList != "" {
+		for _, s := range strings.Split(k.SurveyList, ",") {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return err
+			}
+			k.Surveys = append(k.Surveys, id)
+		}
+	}
+	return nil
+}
+
+// Permits reports whether the key carries a permission on a survey
+func (k APIKey) Permits(permission string, surveyId uuid.UUID) bool {
+	hasPermission := false
+	for _, p := range k.Permissions {
+		if p == permission {
+			hasPermission = true
+		}
+	}
+	if !hasPermission {
+		return false
+	}
+	for _, s := range k.Surveys {
+		if s == surveyId {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey generates a key and returns it along with its prefix
+func NewAPIKey() (string, string, error) {
+	b := make([]byte, 38)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(b[:6])
+	return apiKeyScheme + "_" + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[6:]), prefix, nil
+}
+
+// APIKeyPrefix returns the prefix of a key, or false if the key is not in the issued format
+func APIKeyPrefix(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyScheme || len(parts[1]) != 12 || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// HashAPIKey returns the hex SHA-256 hash stored for a key.  Keys are long random values, so a fast hash
+// is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
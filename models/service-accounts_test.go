@@ -0,0 +1,56 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceAccountName(t *testing.T) {
+	assert.Equal(t, []string{"name"}, fields(ServiceAccount{Name: " "}.Validate()))
+	assert.Equal(t, []string{"name"}, fields(ServiceAccount{Name: strings.Repeat("a", 101)}.Validate()))
+	assert.Empty(t, ServiceAccount{Name: "nsi-etl", Description: "Nightly element load"}.Validate())
+}
+
+func TestAPIKeyScope(t *testing.T) {
+	assert.Equal(t, []string{"permissions", "surveys"}, fields((&APIKey{}).Validate()))
+	k := APIKey{Permissions: []string{PermElementsWrite, "elements:delete"}, Surveys: []uuid.UUID{uuid.New()}, ExpiresInDays: MaxAPIKeyDays + 1}
+	assert.Equal(t, []string{"permissions[1]", "expiresInDays"}, fields(k.Validate()))
+	k.Permissions = []string{PermElementsWrite, PermReportRead}
+	k.ExpiresInDays = 0
+	assert.Empty(t, k.Validate())
+	k.Surveys = append(k.Surveys, uuid.New(), k.Surveys[0])
+	assert.Empty(t, k.Validate())
+	assert.Len(t, k.Surveys, 2, "repeated surveys are dropped")
+}
+
+func TestAPIKeyPermits(t *testing.T) {
+	surveyId := uuid.New()
+	k := APIKey{PermissionList: PermElementsWrite + "," + PermReportRead, SurveyList: surveyId.String()}
+	assert.NoError(t, k.Expand())
+	assert.True(t, k.Permits(PermReportRead, surveyId))
+	assert.False(t, k.Permits(PermElementsRead, surveyId))
+	assert.False(t, k.Permits(PermReportRead, uuid.New()))
+	k = APIKey{}
+	assert.NoError(t, k.Expand())
+	assert.Equal(t, []string{}, k.Permissions)
+	assert.Equal(t, []uuid.UUID{}, k.Surveys)
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, prefix, err := NewAPIKey()
+	assert.NoError(t, err)
+	parsed, ok := APIKeyPrefix(key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, parsed)
+	other, _, _ := NewAPIKey()
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, HashAPIKey(key), HashAPIKey(other))
+	assert.Len(t, HashAPIKey(key), 64)
+	for _, bad := range []string{"", "nsis_abc_secret", "key_" + prefix + "_secret", "nsis_" + prefix + "_"} {
+		_, ok := APIKeyPrefix(bad)
+		assert.False(t, ok, bad)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	StrategySurveyOrder  = "survey_order"
+	StrategyNearest      = "nearest"
+	StrategyRandom       = "random"
+	StrategyBlockCluster = "block_cluster"
+)
+
+// SurveySettings holds the per survey configuration of the assignment engine.  Zero limits are unlimited
+// and a zero control rate issues control elements as members progress past their survey order.
+type SurveySettings struct {
+	SurveyID           uuid.UUID `db:"survey_id" json:"surveyId"`
+	AssignmentStrategy string    `db:"assignment_strategy" json:"assignmentStrategy"`
+	MaxAssignments     int       `db:"max_assignments" json:"maxAssignmentsPerMember"`
+	DailyCap           int       `db:"daily_cap" json:"dailyCap"`
+	ControlRate        int       `db:"control_rate" json:"controlRate"`
+}
+
+func DefaultSurveySettings(surveyId uuid.UUID) SurveySettings {
+	return SurveySettings{
+		SurveyID:           surveyId,
+		AssignmentStrategy: StrategySurveyOrder,
+	}
+}
+
+// Validate checks the limits of the settings.  The assignment strategy is checked against the
+// registered strategies by the store.
+func (s SurveySettings) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if s.MaxAssignments < 0 {
+		errs.add("maxAssignmentsPerMember", "must be greater than or equal to 0")
+	}
+	if s.DailyCap < 0 {
+		errs.add("dailyCap", "must be greater than or equal to 0")
+	}
+	if s.ControlRate < 0 {
+		errs.add("controlRate", "must be greater than or equal to 0")
+	}
+	return errs
+}
+
+// MemberAssignmentCounts tallies the assignments a member has been issued in a survey
+type MemberAssignmentCounts struct {
+	Total    int `db:"total" json:"total"`
+	Today    int `db:"today" json:"today"`
+	Regular  int `db:"regular" json:"regular"`
+	Controls int `db:"controls" json:"controls"`
+}
+
+// Remaining returns the number of further assignments the settings allow a member, or -1 if unlimited
+func (s SurveySettings) Remaining(counts MemberAssignmentCounts) int {
+	return tighterLimit(limitLeft(s.MaxAssignments, counts.Total), limitLeft(s.DailyCap, counts.Today))
+}
+
+func limitLeft(limit int, used int) int {
+	switch {
+	case limit <= 0:
+		return -1
+	case used >= limit:
+		return 0
+	}
+	return limit - used
+}
+
+func tighterLimit(a int, b int) int {
+	if a < 0 || (b >= 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// ControlDue reports whether a member's next element should be a control element under the control
+// rate, i.e. they have been issued rate regular elements since their last control
+func (s SurveySettings) ControlDue(counts MemberAssignmentCounts) bool {
+	return s.ControlRate > 0 && counts.Regular >= s.ControlRate*(counts.Controls+1)
+}
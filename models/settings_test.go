@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemainingAssignments(t *testing.T) {
+	counts := MemberAssignmentCounts{Total: 40, Today: 8}
+	assert.Equal(t, -1, SurveySettings{}.Remaining(counts))
+	assert.Equal(t, 10, SurveySettings{MaxAssignments: 50}.Remaining(counts))
+	assert.Equal(t, 2, SurveySettings{MaxAssignments: 50, DailyCap: 10}.Remaining(counts))
+	assert.Equal(t, 0, SurveySettings{MaxAssignments: 30, DailyCap: 10}.Remaining(counts))
+	assert.Equal(t, 0, SurveySettings{DailyCap: 5}.Remaining(counts))
+}
+
+func TestControlDue(t *testing.T) {
+	settings := SurveySettings{ControlRate: 20}
+	assert.False(t, settings.ControlDue(MemberAssignmentCounts{Regular: 19}))
+	assert.True(t, settings.ControlDue(MemberAssignmentCounts{Regular: 20}))
+	assert.False(t, settings.ControlDue(MemberAssignmentCounts{Regular: 20, Controls: 1}))
+	assert.True(t, settings.ControlDue(MemberAssignmentCounts{Regular: 45, Controls: 1}))
+	assert.False(t, SurveySettings{}.ControlDue(MemberAssignmentCounts{Regular: 100}))
+}
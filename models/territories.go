@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var blockGroupPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// Territory is an area of a survey assigned to a crew.  An element falls within a territory if its
+// NSI location is inside the polygon or its census block is in one of the listed block groups.
+type Territory struct {
+	ID             uuid.UUID    `db:"id" json:"id"`
+	SurveyID       uuid.UUID    `db:"survey_id" json:"surveyId"`
+	Name           string       `db:"name" json:"name"`
+	Boundary       string       `db:"boundary" json:"-"`
+	BlockGroupList string       `db:"block_groups" json:"-"`
+	BlockGroups    []string     `db:"-" json:"blockGroups"`
+	Polygon        [][2]float64 `db:"-" json:"polygon"`
+	Members        []string     `db:"-" json:"members"`
+}
+
+type TerritoryMember struct {
+	TerritoryID uuid.UUID `db:"territory_id" json:"territoryId"`
+	UserID      string    `db:"user_id" json:"userId"`
+	UserName    string    `db:"user_name" json:"userName"`
+}
+
+// TerritoryCoverage summarizes the progress of the regular elements that fall within a territory
+type TerritoryCoverage struct {
+	TerritoryID uuid.UUID `db:"territory_id" json:"territoryId"`
+	Name        string    `db:"name" json:"name"`
+	Members     int       `db:"members" json:"members"`
+	Elements    int       `db:"elements" json:"elements"`
+	Assigned    int       `db:"assigned" json:"assigned"`
+	Completed   int       `db:"completed" json:"completed"`
+}
+
+// Validate checks a territory definition.  A territory needs a polygon of at least three
+// longitude/latitude vertices, a list of 12 digit block group FIPS codes, or both.
+func (t Territory) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+	if strings.TrimSpace(t.Name) == "" {
+		errs.add("name", "is required")
+	}
+	if len(t.Polygon) == 0 && len(t.BlockGroups) == 0 {
+		errs.add("polygon", "a polygon or block groups are required")
+	}
+	if len(t.Polygon) > 0 && len(t.Polygon) < 3 {
+		errs.add("polygon", "must have at least 3 vertices")
+	}
+	for i, p := range t.Polygon {
+		if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+			errs.add(fmt.Sprintf("polygon[%d]", i), "is not a valid longitude/latitude")
+		}
+	}
+	for i, bg := range t.BlockGroups {
+		if !blockGroupPattern.MatchString(bg) {
+			errs.add(fmt.Sprintf("blockGroups[%d]", i), "%s is not a 12 digit block group FIPS code", bg)
+		}
+	}
+	return errs
+}
+
+// FormatPolygon renders polygon vertices in the postgres polygon text format ((x1,y1),...,(xn,yn)).
+// An empty polygon renders as an empty string.
+func FormatPolygon(polygon [][2]float64) string {
+	if len(polygon) == 0 {
+		return ""
+	}
+	points := make([]string, len(polygon))
+	for i, p := range polygon {
+		points[i] = fmt.Sprintf("(%s,%s)", strconv.FormatFloat(p[0], 'f', -1, 64), strconv.FormatFloat(p[1], 'f', -1, 64))
+	}
+	return "(" + strings.Join(points, ",") + ")"
+}
+
+// ParsePolygon reads the postgres polygon text format
+func ParsePolygon(s string) ([][2]float64, error) {
+	s = strings.NewReplacer("(", "", ")", "", " ", "").Replace(s)
+	if s == "" {
+		return nil, nil
+	}
+	coords := strings.Split(s, ",")
+	if len(coords)%2 != 0 {
+		return nil, fmt.Errorf("invalid polygon %s", s)
+	}
+	polygon := make([][2]float64, len(coords)/2)
+	for i := range polygon {
+		for j := 0; j < 2; j++ {
+			v, err := strconv.ParseFloat(coords[2*i+j], 64)
+			if err != nil {
+				return nil, err
+			}
+			polygon[i][j] = v
+		}
+	}
+	return polygon, nil
+}
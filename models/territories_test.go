@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolygonRoundTrip(t *testing.T) {
+	polygon := [][2]float64{{-90.1, 29.9}, {-90, 29.9}, {-90, 30.05}}
+	s := FormatPolygon(polygon)
+	assert.Equal(t, "((-90.1,29.9),(-90,29.9),(-90,30.05))", s)
+	parsed, err := ParsePolygon(s)
+	assert.NoError(t, err)
+	assert.Equal(t, polygon, parsed)
+
+	parsed, err = ParsePolygon("")
+	assert.NoError(t, err)
+	assert.Empty(t, parsed)
+	assert.Equal(t, "", FormatPolygon(nil))
+}
+
+func TestTerritoryValidation(t *testing.T) {
+	territory := Territory{Name: "Crew A", BlockGroups: []string{"220710001001"}}
+	assert.Empty(t, territory.Validate())
+
+	assert.Equal(t, []string{"name", "polygon"}, fields(Territory{}.Validate()))
+
+	territory = Territory{
+		Name:        "Crew B",
+		Polygon:     [][2]float64{{-90.1, 29.9}, {-190, 29.9}},
+		BlockGroups: []string{"2207100010"},
+	}
+	assert.Equal(t, []string{"polygon", "polygon[1]", "blockGroups[0]"}, fields(territory.Validate()))
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserProfile is a user's directory record.  Name and email are refreshed from the token claims each
+// time the user authenticates.  Inactive users keep their history but are not issued new assignments.
+type UserProfile struct {
+	UserID     string     `db:"user_id" json:"userId"`
+	Username   string     `db:"user_name" json:"userName"`
+	Email      string     `db:"email" json:"email"`
+	Active     bool       `db:"active" json:"active"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updatedAt"`
+	LastSeenAt *time.Time `db:"last_seen_at" json:"lastSeenAt"`
+}
+
+// UserMembership summarizes a user's membership and progress in one survey
+type UserMembership struct {
+	SurveyID  uuid.UUID `db:"survey_id" json:"surveyId"`
+	Title     string    `db:"title" json:"title"`
+	Role      string    `db:"role" json:"role"`
+	Group     string    `db:"group_name" json:"group"`
+	Open      int       `db:"open" json:"openAssignments"`
+	Completed int       `db:"completed" json:"completedAssignments"`
+}
+
+type UserDetail struct {
+	UserProfile
+	Memberships []UserMembership `json:"memberships"`
+}
+
+// UserMerge folds the records of one user into another
+type UserMerge struct {
+	Into string `json:"into"`
+}
+
+func (m UserMerge) Validate(userId string) ValidationErrors {
+	errs := ValidationErrors{}
+	switch m.Into {
+	case "":
+		errs.add("into", "is required")
+	case userId:
+		errs.add("into", "must be a different user")
+	}
+	return errs
+}
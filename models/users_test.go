@@ -0,0 +1,13 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateUserMerge(t *testing.T) {
+	assert.Empty(t, UserMerge{Into: "987654"}.Validate("123456"))
+	assert.Equal(t, []string{"into"}, fields(UserMerge{}.Validate("123456")))
+	assert.Equal(t, []string{"into"}, fields(UserMerge{Into: "123456"}.Validate("123456")))
+}
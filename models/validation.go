@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (ve *ValidationErrors) add(field string, format string, args ...interface{}) {
+	*ve = append(*ve, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (ve ValidationErrors) has(field string) bool {
+	for _, fe := range ve {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValues maps SurveyStructure JSON field names to their current values
+func (s SurveyStructure) fieldValues() map[string]interface{} {
+	return map[string]interface{}{
+		"fdId":          float64(s.FDID),
+		"x":             s.X,
+		"y":             s.Y,
+		"cbfips":        s.CBfips,
+		"occupancyType": s.OccupancyType,
+		"damcat":        s.Damcat,
+		"found_ht":      s.FoundHt,
+		"stories":       s.Stories,
+		"sq_ft":         s.SqFt,
+		"found_type":    s.FoundType,
+		"rsmeans_type":  s.RsmeansType,
+		"quality":       s.Quality,
+		"const_type":    s.ConstType,
+		"garage":        s.Garage,
+		"roof_style":    s.RoofStyle,
+	}
+}
+
+// Validate checks a submitted structure against the default attribute domains
+func (s SurveyStructure) Validate() ValidationErrors {
+	return ValidateStructure(s, StructureAttributes)
+}
+
+// ValidateStructure checks a submitted structure against an attribute set and returns
+// every field that fails.  Structures flagged as invalid are not required to carry
+// attribute values, but any values they do carry must still be in the domain.
+func ValidateStructure(s SurveyStructure, attributes []Attribute) ValidationErrors {
+	errs := ValidationErrors{}
+	if s.SAID == uuid.Nil {
+		errs.add("saId", "is required")
+	}
+	return append(errs, validateAttributes(s, attributes)...)
+}
+
+// ValidateDraft checks a partially completed structure.  Drafts may hold values outside
+// the domain, so only the limits that would prevent the draft from being stored are checked.
+func ValidateDraft(s SurveyStructure, attributes []Attribute) ValidationErrors {
+	errs := ValidationErrors{}
+	if s.SAID == uuid.Nil {
+		errs.add("saId", "is required")
+	}
+	values := s.fieldValues()
+	for _, attr := range attributes {
+		if v, ok := values[attr.Field].(string); ok && attr.MaxLength > 0 && len(v) > attr.MaxLength {
+			errs.add(attr.Field, "exceeds the maximum length of %d", attr.MaxLength)
+		}
+	}
+	return errs
+}
+
+// ValidateNewStructure checks a surveyor added structure against an attribute set.  New
+// structures have no NSI fd_id or assignment, so only their attribute values are checked.
+func ValidateNewStructure(ns NewStructure, attributes []Attribute) ValidationErrors {
+	attrs := []Attribute{}
+	for _, attr := range attributes {
+		if attr.Column != "fd_id" {
+			attrs = append(attrs, attr)
+		}
+	}
+	return validateAttributes(ns.SurveyStructure(), attrs)
+}
+
+func validateAttributes(s SurveyStructure, attributes []Attribute) ValidationErrors {
+	errs := ValidationErrors{}
+	values := s.fieldValues()
+	for _, attr := range attributes {
+		val, ok := values[attr.Field]
+		if !ok {
+			continue
+		}
+		switch v := val.(type) {
+		case string:
+			validateString(&errs, attr, v, s.InvalidStructure)
+		case float64:
+			validateNumber(&errs, attr, v, s.InvalidStructure)
+		}
+	}
+	if !s.InvalidStructure && s.OccupancyType != "" && s.Damcat != "" && !errs.has("damcat") {
+		if expected := DamcatForOccupancy(s.OccupancyType); expected != "" && expected != s.Damcat {
+			errs.add("damcat", "%s is not consistent with occupancy type %s (expected %s)", s.Damcat, s.OccupancyType, expected)
+		}
+	}
+	return errs
+}
+
+func validateString(errs *ValidationErrors, attr Attribute, v string, invalidStructure bool) {
+	if v == "" {
+		if attr.Required && !invalidStructure {
+			errs.add(attr.Field, "is required")
+		}
+		return
+	}
+	if attr.MaxLength > 0 && len(v) > attr.MaxLength {
+		errs.add(attr.Field, "exceeds the maximum length of %d", attr.MaxLength)
+		return
+	}
+	if len(attr.Values) > 0 && !attr.Allows(v) {
+		errs.add(attr.Field, "%s is not a valid value", v)
+	}
+}
+
+func validateNumber(errs *ValidationErrors, attr Attribute, v float64, invalidStructure bool) {
+	if attr.Required && v == 0 {
+		if !invalidStructure {
+			errs.add(attr.Field, "is required")
+		}
+		return
+	}
+	if attr.Type == AttributeInteger && v != float64(int64(v)) {
+		errs.add(attr.Field, "must be a whole number")
+	}
+	if attr.Min != nil && v < *attr.Min {
+		errs.add(attr.Field, "must be greater than or equal to %g", *attr.Min)
+	}
+	if attr.Max != nil && v > *attr.Max {
+		errs.add(attr.Field, "must be less than or equal to %g", *attr.Max)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func validStructure() SurveyStructure {
+	return SurveyStructure{
+		SAID:          uuid.New(),
+		FDID:          95009,
+		X:             -90.1,
+		Y:             30.2,
+		CBfips:        "220710001001000",
+		OccupancyType: "RES1",
+		Damcat:        "RES",
+		FoundHt:       2.5,
+		Stories:       1,
+		SqFt:          1800,
+		FoundType:     "S",
+		ConstType:     "W",
+	}
+}
+
+func fields(verrs ValidationErrors) []string {
+	f := []string{}
+	for _, fe := range verrs {
+		f = append(f, fe.Field)
+	}
+	return f
+}
+
+func TestValidStructure(t *testing.T) {
+	assert.Empty(t, validStructure().Validate())
+}
+
+func TestDomainErrors(t *testing.T) {
+	s := validStructure()
+	s.OccupancyType = "RES9"
+	s.FoundType = "X"
+	s.Garage = "Underground"
+	assert.Equal(t, []string{"occupancyType", "found_type", "garage"}, fields(s.Validate()))
+}
+
+func TestRangeErrors(t *testing.T) {
+	s := validStructure()
+	s.FoundHt = 999
+	s.Stories = -1
+	s.X = 200
+	assert.Equal(t, []string{"x", "found_ht", "stories"}, fields(s.Validate()))
+}
+
+func TestLengthErrors(t *testing.T) {
+	s := validStructure()
+	s.Damcat = "RESIDENTIAL"
+	assert.Equal(t, []string{"damcat"}, fields(s.Validate()))
+}
+
+func TestDamcatConsistency(t *testing.T) {
+	s := validStructure()
+	s.Damcat = "COM"
+	assert.Equal(t, []string{"damcat"}, fields(s.Validate()))
+	s.OccupancyType = "GOV1"
+	s.Damcat = "PUB"
+	assert.Empty(t, s.Validate())
+}
+
+func TestInvalidStructureSkipsRequired(t *testing.T) {
+	s := validStructure()
+	s.OccupancyType = ""
+	s.Damcat = ""
+	s.FoundType = ""
+	assert.Len(t, s.Validate(), 3)
+	s.InvalidStructure = true
+	assert.Empty(t, s.Validate())
+}
+
+func TestMissingAssignment(t *testing.T) {
+	s := validStructure()
+	s.SAID = uuid.Nil
+	assert.Equal(t, []string{"saId"}, fields(s.Validate()))
+}
+
+func TestDomainOverrides(t *testing.T) {
+	overrides := []SurveyDomainValue{
+		{Column: "found_type", Code: "S", Description: "Slab"},
+		{Column: "found_type", Code: "P", Description: "Pier"},
+	}
+	attrs := ApplyDomainOverrides(StructureAttributes, overrides)
+	s := validStructure()
+	s.FoundType = "B"
+	assert.Empty(t, ValidateStructure(s, StructureAttributes))
+	assert.Equal(t, []string{"found_type"}, fields(ValidateStructure(s, attrs)))
+	attr, _ := FindAttribute(StructureAttributes, "found_type")
+	assert.Len(t, attr.Values, len(FoundationTypes))
+}
+
+func TestValidateNewStructure(t *testing.T) {
+	ns := NewStructure{
+		X:             -90.1,
+		Y:             30.2,
+		OccupancyType: "COM1",
+		Damcat:        "COM",
+		FoundType:     "S",
+	}
+	assert.Empty(t, ValidateNewStructure(ns, StructureAttributes))
+	ns.X = 0
+	ns.Damcat = "RES"
+	assert.Equal(t, []string{"x", "damcat"}, fields(ValidateNewStructure(ns, StructureAttributes)))
+}
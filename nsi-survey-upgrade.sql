@@ -0,0 +1,323 @@
+-- Upgrades a database created by an earlier nsi-survey.sql to the current schema.  Safe to run more than once.
+
+alter table survey add column if not exists archived boolean not null default false;
+alter table survey add column if not exists archived_at timestamptz;
+
+alter table users add column if not exists email varchar(255);
+alter table users add column if not exists active boolean not null default true;
+alter table users add column if not exists updated_at timestamptz not null default now();
+alter table users add column if not exists last_seen_at timestamptz;
+
+alter table survey_member add column if not exists role varchar(20) not null default 'surveyor' check (role in ('owner','coordinator','reviewer','surveyor','viewer'));
+alter table survey_member add column if not exists group_name varchar(100);
+-- owners recorded before roles were introduced only carry is_owner
+update survey_member set role='owner' where is_owner and role<>'owner';
+
+alter table survey_assignment add column if not exists assigned_at timestamptz not null default now();
+
+alter table survey_result add column if not exists version int not null default 1;
+alter table survey_result add column if not exists updated_by varchar(50);
+alter table survey_result add column if not exists updated_at timestamptz not null default now();
+
+create table if not exists survey_domain(
+    survey_id uuid not null,
+    column_name varchar(50) not null,
+    code varchar(50) not null,
+    description text,
+    ordinal int not null default 0,
+    PRIMARY KEY(survey_id,column_name,code),
+    CONSTRAINT fk_sd_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id)
+);
+
+
+
+create table if not exists survey_attachment(
+    id uuid not null primary key,
+    sa_id uuid not null,
+    file_name varchar(255) not null default '',
+    content_type varchar(100) not null default '',
+    size bigint not null default 0,
+    blob_key text not null default '',
+    thumbnail_key text not null default '',
+    note text not null default '',
+    uploaded_by varchar(50) not null,
+    uploaded_at timestamptz not null default now(),
+    CONSTRAINT fk_sat_assignment
+        FOREIGN KEY(sa_id)
+            REFERENCES survey_assignment(id),
+    CONSTRAINT fk_sat_user
+        FOREIGN KEY(uploaded_by)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sat_said ON survey_attachment (sa_id);
+
+
+create table if not exists survey_comment(
+    id uuid not null default gen_random_uuid() primary key,
+    survey_id uuid not null,
+    se_id uuid not null,
+    sa_id uuid,
+    parent_id uuid,
+    user_id varchar(50) not null,
+    body text not null,
+    created_at timestamptz not null default now(),
+    CONSTRAINT fk_sc_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id),
+    CONSTRAINT fk_sc_element
+        FOREIGN KEY(se_id)
+            REFERENCES survey_element(id),
+    CONSTRAINT fk_sc_assignment
+        FOREIGN KEY(sa_id)
+            REFERENCES survey_assignment(id),
+    CONSTRAINT fk_sc_parent
+        FOREIGN KEY(parent_id)
+            REFERENCES survey_comment(id),
+    CONSTRAINT fk_sc_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sc_seid ON survey_comment (se_id);
+
+create table if not exists survey_flag(
+    id uuid not null default gen_random_uuid() primary key,
+    survey_id uuid not null,
+    se_id uuid not null,
+    sa_id uuid,
+    flag_type varchar(20) not null,
+    note text not null default '',
+    raised_by varchar(50) not null,
+    raised_at timestamptz not null default now(),
+    resolved boolean not null default false,
+    resolved_by varchar(50),
+    resolved_at timestamptz,
+    resolution text not null default '',
+    CONSTRAINT fk_sf_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id),
+    CONSTRAINT fk_sf_element
+        FOREIGN KEY(se_id)
+            REFERENCES survey_element(id),
+    CONSTRAINT fk_sf_assignment
+        FOREIGN KEY(sa_id)
+            REFERENCES survey_assignment(id),
+    CONSTRAINT fk_sf_raised_by
+        FOREIGN KEY(raised_by)
+            REFERENCES users(user_id),
+    CONSTRAINT fk_sf_resolved_by
+        FOREIGN KEY(resolved_by)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sf_survey_resolved ON survey_flag (survey_id,resolved);
+
+
+create sequence if not exists survey_new_structure_seq;
+
+create table if not exists survey_new_structure(
+    id uuid not null default gen_random_uuid() primary key,
+    survey_id uuid not null,
+    provisional_id varchar(20) not null unique default 'NEW-' || lpad(nextval('survey_new_structure_seq')::text,6,'0'),
+    submitted_by varchar(50) not null,
+    submitted_at timestamptz not null default now(),
+    status varchar(10) not null default 'pending',
+    reviewed_by varchar(50),
+    reviewed_at timestamptz,
+    review_note text not null default '',
+    X double precision not null,
+    Y double precision not null,
+    cbfips varchar(15),
+    occtype varchar(9),
+    st_damcat varchar(3),
+    found_ht double precision,
+    num_story double precision,
+    sqft double precision,
+    found_type varchar(4),
+    rsmeans_type varchar(50),
+    quality varchar(50),
+    const_type varchar(50),
+    garage varchar(50),
+    roof_style varchar(50),
+    CONSTRAINT fk_sns_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id),
+    CONSTRAINT fk_sns_submitted_by
+        FOREIGN KEY(submitted_by)
+            REFERENCES users(user_id),
+    CONSTRAINT fk_sns_reviewed_by
+        FOREIGN KEY(reviewed_by)
+            REFERENCES users(user_id)
+);
+
+
+create table if not exists idempotency_key(
+    user_id varchar(50) not null,
+    idempotency_key varchar(100) not null,
+    request_hash char(64) not null,
+    status_code int not null default 0,
+    response text not null default '',
+    content_type varchar(100) not null default '',
+    etag varchar(100) not null default '',
+    created_at timestamptz not null default now(),
+    PRIMARY KEY(user_id,idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ik_created ON idempotency_key (created_at);
+
+
+create table if not exists survey_setting(
+    survey_id uuid not null primary key,
+    assignment_strategy varchar(20) not null default 'survey_order',
+    max_assignments int not null default 0,
+    daily_cap int not null default 0,
+    control_rate int not null default 0,
+    CONSTRAINT fk_ss_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id)
+);
+
+
+create table if not exists survey_territory(
+    id uuid not null default gen_random_uuid() primary key,
+    survey_id uuid not null,
+    name varchar(100) not null,
+    boundary polygon,
+    block_groups varchar(12)[] not null default '{}',
+    UNIQUE(survey_id,name),
+    CONSTRAINT fk_st_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id)
+);
+
+create table if not exists survey_territory_member(
+    territory_id uuid not null,
+    user_id varchar(50) not null,
+    primary key (territory_id,user_id),
+    CONSTRAINT fk_stm_territory
+        FOREIGN KEY(territory_id)
+            REFERENCES survey_territory(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_stm_user
+        FOREIGN KEY(user_id)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stm_user ON survey_territory_member (user_id);
+
+
+create table if not exists survey_invitation(
+    id uuid not null default gen_random_uuid() primary key,
+    survey_id uuid not null,
+    email varchar(255),
+    subject varchar(50),
+    role varchar(20) not null default 'surveyor' check (role in ('owner','coordinator','reviewer','surveyor','viewer')),
+    status varchar(20) not null default 'pending' check (status in ('pending','accepted','revoked')),
+    invited_by varchar(50) not null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    accepted_by varchar(50),
+    accepted_at timestamptz,
+    check (email is not null or subject is not null),
+    CONSTRAINT fk_si_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id),
+    CONSTRAINT fk_si_invited_by
+        FOREIGN KEY(invited_by)
+            REFERENCES users(user_id),
+    CONSTRAINT fk_si_accepted_by
+        FOREIGN KEY(accepted_by)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_si_pending_email ON survey_invitation (lower(email)) where status='pending';
+CREATE INDEX IF NOT EXISTS idx_si_pending_subject ON survey_invitation (subject) where status='pending';
+
+
+create table if not exists survey_group(
+    survey_id uuid not null,
+    group_name varchar(100) not null,
+    role varchar(20) not null default 'surveyor' check (role in ('owner','coordinator','reviewer','surveyor','viewer')),
+    primary key (survey_id,group_name),
+    CONSTRAINT fk_sg_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sg_group ON survey_group (group_name);
+
+
+create table if not exists service_account(
+    id uuid not null default gen_random_uuid() primary key,
+    name varchar(100) not null unique,
+    description text not null default '',
+    active boolean not null default true,
+    created_by varchar(50) not null,
+    created_at timestamptz not null default now(),
+    CONSTRAINT fk_sva_created_by
+        FOREIGN KEY(created_by)
+            REFERENCES users(user_id)
+);
+
+create table if not exists api_key(
+    id uuid not null default gen_random_uuid() primary key,
+    account_id uuid not null,
+    name varchar(100) not null default '',
+    prefix varchar(12) not null unique,
+    key_hash varchar(64) not null,
+    permissions varchar(30)[] not null,
+    created_by varchar(50) not null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    CONSTRAINT fk_ak_account
+        FOREIGN KEY(account_id)
+            REFERENCES service_account(id),
+    CONSTRAINT fk_ak_created_by
+        FOREIGN KEY(created_by)
+            REFERENCES users(user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ak_account ON api_key (account_id);
+
+create table if not exists api_key_survey(
+    key_id uuid not null,
+    survey_id uuid not null,
+    primary key (key_id,survey_id),
+    CONSTRAINT fk_aks_key
+        FOREIGN KEY(key_id)
+            REFERENCES api_key(id)
+            ON DELETE CASCADE,
+    CONSTRAINT fk_aks_survey
+        FOREIGN KEY(survey_id)
+            REFERENCES survey(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_aks_survey ON api_key_survey (survey_id);
+
+alter table survey_domain add column if not exists ordinal int not null default 0;
+alter table idempotency_key add column if not exists etag varchar(100) not null default '';
+
+
+-- an element can only be assigned to a user once.  Where a user holds the same element more than once keep the
+-- completed assignment (or the one with a result), move the attachments, comments and flags of the others to it
+-- and drop the others along with their results.
+create temporary table duplicate_assignment as
+    select id,first_value(id) over w as kept_id,row_number() over w as n from (
+        select sa.*,sr.id is not null as has_result
+        from survey_assignment sa
+        left join survey_result sr on sr.sa_id=sa.id
+    ) sa
+    window w as (partition by se_id,assigned_to order by coalesce(completed,false) desc,has_result desc,assigned_at,id);
+delete from duplicate_assignment where n=1;
+update survey_attachment sat set sa_id=d.kept_id from duplicate_assignment d where sat.sa_id=d.id;
+update survey_comment sc set sa_id=d.kept_id from duplicate_assignment d where sc.sa_id=d.id;
+update survey_flag sf set sa_id=d.kept_id from duplicate_assignment d where sf.sa_id=d.id;
+delete from survey_result where sa_id in (select id from duplicate_assignment);
+delete from survey_assignment where id in (select id from duplicate_assignment);
+drop table duplicate_assignment;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sa_seid_user ON survey_assignment (se_id,assigned_to);
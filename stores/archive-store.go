@@ -0,0 +1,50 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// SetArchived archives or restores a survey.  Archived surveys are hidden from their members and
+// reject changes until they are restored.
+func (ss *SurveyStore) SetArchived(surveyId uuid.UUID, archived bool) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&surveyTable).
+		StatementKey("archive").
+		Params(surveyId, archived).
+		Dest(&id).
+		Fetch()
+}
+
+// IsArchived reports whether a survey is archived.  Surveys that do not exist are not archived.
+func (ss *SurveyStore) IsArchived(surveyId uuid.UUID) (bool, error) {
+	var archived bool
+	err := ss.DS.Select().
+		DataSet(&surveyTable).
+		StatementKey("isArchived").
+		Params(surveyId).
+		Dest(&archived).
+		Fetch()
+	if err != nil && err.Error() == NoResults {
+		return false, nil
+	}
+	return archived, err
+}
+
+// PurgeSurvey permanently deletes a survey and everything recorded for it in a single transaction.
+// Attachment content is not removed from the blob store.
+func (ss *SurveyStore) PurgeSurvey(surveyId uuid.UUID) error {
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		pgtx := tx.PgxTx()
+		for _, stmt := range purgeStatements {
+			if _, err := pgtx.Exec(context.Background(), stmt, surveyId); err != nil {
+				panic(err)
+			}
+		}
+	})
+	invalidateSurveyRoles(surveyId)
+	return err
+}
@@ -0,0 +1,182 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+var ErrAssignmentLimit = errors.New("Assignment limit reached")
+
+// AssignmentStrategy selects the next regular (non control) element to issue to a user.  Implementations
+// must only return elements that have not been assigned and should lock the row they return so that
+// concurrent assignments skip it.  A nil element means there is nothing left to assign.
+type AssignmentStrategy interface {
+	NextElement(ds goquery.DataStore, tx *goquery.Tx, userId string, surveyId uuid.UUID) (*models.SurveyElement, error)
+}
+
+// statementStrategy is an AssignmentStrategy backed by a statement in the assignment engine table
+type statementStrategy string
+
+func (key statementStrategy) NextElement(ds goquery.DataStore, tx *goquery.Tx, userId string, surveyId uuid.UUID) (*models.SurveyElement, error) {
+	return nextEngineElement(ds, tx, string(key), userId, surveyId)
+}
+
+var assignmentStrategies = map[string]AssignmentStrategy{
+	models.StrategySurveyOrder:  statementStrategy("next-survey-order"),
+	models.StrategyNearest:      statementStrategy("next-nearest"),
+	models.StrategyRandom:       statementStrategy("next-random"),
+	models.StrategyBlockCluster: statementStrategy("next-block-cluster"),
+}
+
+// RegisterAssignmentStrategy makes a strategy selectable in the survey settings under name
+func RegisterAssignmentStrategy(name string, strategy AssignmentStrategy) {
+	assignmentStrategies[name] = strategy
+}
+
+func ValidAssignmentStrategy(name string) bool {
+	_, ok := assignmentStrategies[name]
+	return ok
+}
+
+func nextEngineElement(ds goquery.DataStore, tx *goquery.Tx, key string, userId string, surveyId uuid.UUID) (*models.SurveyElement, error) {
+	elements := []models.SurveyElement{}
+	err := ds.Select().
+		DataSet(&assignmentEngineTable).
+		Tx(tx).
+		StatementKey(key).
+		Params(userId, surveyId).
+		Dest(&elements).
+		Fetch()
+	if err != nil || len(elements) == 0 {
+		return nil, err
+	}
+	return &elements[0], nil
+}
+
+// AssignNext issues up to n new elements to a user and returns the new assignments.  Control elements
+// are issued to every user after every control rate regular elements the user is issued when the survey
+// sets a control rate.  Otherwise they are issued once the survey (under the survey order strategy) or
+// the user (under any other strategy) has progressed past their survey order.  All other elements are
+// issued to a single user in the order chosen by the survey's assignment strategy; an element taken by a
+// concurrent assignment is skipped and the strategy asked again.  If the survey's assignment limits
+// prevent issuing any element, ErrAssignmentLimit is returned, and deactivated users are refused with
+// ErrUserInactive.
+func (ss *SurveyStore) AssignNext(userId string, surveyId uuid.UUID, n int) ([]models.SurveyAssignment, error) {
+	active, err := ss.IsActiveUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrUserInactive
+	}
+	settings, err := ss.GetSurveySettings(surveyId)
+	if err != nil {
+		return nil, err
+	}
+	strategy, ok := assignmentStrategies[settings.AssignmentStrategy]
+	if !ok {
+		return nil, fmt.Errorf("Unknown assignment strategy %s", settings.AssignmentStrategy)
+	}
+	assignments := []models.SurveyAssignment{}
+	limited := false
+	err = ss.DS.Transaction(func(tx goquery.Tx) {
+		// serializes concurrent requests from the member so both cannot count the same remaining limit
+		_, err := tx.PgxTx().Exec(context.Background(), assignmentEngineTable.Statements["lockMember"], surveyId, userId)
+		if err != nil {
+			panic(err)
+		}
+		counts := models.MemberAssignmentCounts{}
+		err = ss.DS.Select().
+			DataSet(&assignmentEngineTable).
+			Tx(&tx).
+			StatementKey("memberCounts").
+			Params(userId, surveyId).
+			Dest(&counts).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		if remaining := settings.Remaining(counts); remaining >= 0 && remaining < n {
+			n = remaining
+			limited = remaining == 0
+		}
+		controlKey := "nextControl"
+		switch {
+		case settings.ControlRate > 0:
+			controlKey = "nextControlByRate"
+		case settings.AssignmentStrategy != models.StrategySurveyOrder:
+			controlKey = "nextControlByProgress"
+		}
+		for len(assignments) < n {
+			var se *models.SurveyElement
+			if settings.ControlRate == 0 || settings.ControlDue(counts) {
+				se, err = nextEngineElement(ss.DS, &tx, controlKey, userId, surveyId)
+				if err != nil {
+					panic(err)
+				}
+			}
+			if se == nil {
+				se, err = strategy.NextElement(ss.DS, &tx, userId, surveyId)
+				if err != nil {
+					panic(err)
+				}
+			}
+			if se == nil {
+				return
+			}
+			var saId uuid.UUID
+			err = ss.DS.Select().
+				DataSet(&assignmentEngineTable).
+				Tx(&tx).
+				StatementKey("assignElement").
+				Params(se.ID, userId).
+				Dest(&saId).
+				Fetch()
+			if err != nil {
+				if err.Error() == NoResults {
+					continue
+				}
+				panic(err)
+			}
+			if se.Is_control {
+				counts.Controls++
+			} else {
+				counts.Regular++
+			}
+			assignments = append(assignments, models.SurveyAssignment{
+				ID:               saId,
+				SurveyElement_ID: se.ID,
+				Assigned:         userId,
+			})
+		}
+	})
+	if err == nil && limited {
+		err = ErrAssignmentLimit
+	}
+	return assignments, err
+}
+
+// GetSurveySettings returns the settings for a survey, or the defaults if none have been saved
+func (ss *SurveyStore) GetSurveySettings(surveyId uuid.UUID) (models.SurveySettings, error) {
+	settings := models.SurveySettings{}
+	err := ss.DS.Select().
+		DataSet(&surveySettingTable).
+		StatementKey("select").
+		Params(surveyId).
+		Dest(&settings).
+		Fetch()
+	if err != nil && err.Error() == NoResults {
+		return models.DefaultSurveySettings(surveyId), nil
+	}
+	return settings, err
+}
+
+func (ss *SurveyStore) UpdateSurveySettings(settings models.SurveySettings) error {
+	return ss.DS.Exec(goquery.NoTx, surveySettingTable.Statements["upsert"],
+		settings.SurveyID, settings.AssignmentStrategy, settings.MaxAssignments, settings.DailyCap, settings.ControlRate)
+}
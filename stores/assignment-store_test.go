@@ -0,0 +1,43 @@
+package stores
+
+import (
+	"testing"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/usace/goquery"
+)
+
+type nilStrategy struct{}
+
+func (nilStrategy) NextElement(ds goquery.DataStore, tx *goquery.Tx, userId string, surveyId uuid.UUID) (*models.SurveyElement, error) {
+	return nil, nil
+}
+
+func TestAssignmentStrategies(t *testing.T) {
+	for _, name := range []string{models.StrategySurveyOrder, models.StrategyNearest, models.StrategyRandom, models.StrategyBlockCluster} {
+		assert.True(t, ValidAssignmentStrategy(name), name)
+	}
+	assert.True(t, ValidAssignmentStrategy(models.DefaultSurveySettings(uuid.New()).AssignmentStrategy))
+	assert.False(t, ValidAssignmentStrategy(""))
+	assert.False(t, ValidAssignmentStrategy("fewest_stories"))
+
+	RegisterAssignmentStrategy("fewest_stories", nilStrategy{})
+	defer delete(assignmentStrategies, "fewest_stories")
+	assert.True(t, ValidAssignmentStrategy("fewest_stories"))
+}
+
+func TestEngineStatements(t *testing.T) {
+	for name, strategy := range assignmentStrategies {
+		if key, ok := strategy.(statementStrategy); ok {
+			stmt, found := assignmentEngineTable.Statements[string(key)]
+			if assert.True(t, found, name) {
+				assert.Contains(t, stmt, "skip locked", name)
+			}
+		}
+	}
+	for _, key := range []string{"nextControl", "nextControlByRate", "nextControlByProgress", "assignElement", "memberCounts"} {
+		assert.NotEmpty(t, assignmentEngineTable.Statements[key], key)
+	}
+}
@@ -0,0 +1,62 @@
+package stores
+
+import (
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// GetSurveyAssignment returns an assignment if it belongs to an element of the survey
+func (ss *SurveyStore) GetSurveyAssignment(surveyId uuid.UUID, saId uuid.UUID) (models.SurveyAssignment, error) {
+	sa := models.SurveyAssignment{}
+	err := ss.DS.Select().
+		DataSet(&surveyAssignmentTable).
+		StatementKey("selectInSurvey").
+		Params(saId, surveyId).
+		Dest(&sa).
+		Fetch()
+	return sa, err
+}
+
+func (ss *SurveyStore) InsertAttachment(a models.Attachment) error {
+	return ss.DS.Exec(goquery.NoTx, attachmentTable.Statements["insert"],
+		a.ID, a.SAID, a.FileName, a.ContentType, a.Size, a.BlobKey, a.ThumbnailKey, a.Note, a.UploadedBy)
+}
+
+// GetAttachment returns an attachment if it belongs to an assignment in the survey
+func (ss *SurveyStore) GetAttachment(surveyId uuid.UUID, attachmentId uuid.UUID) (models.Attachment, error) {
+	a := models.Attachment{}
+	err := ss.DS.Select().
+		DataSet(&attachmentTable).
+		StatementKey("selectById").
+		Params(attachmentId, surveyId).
+		Dest(&a).
+		Fetch()
+	return a, err
+}
+
+func (ss *SurveyStore) GetAssignmentAttachments(saId uuid.UUID) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	err := ss.DS.Select().
+		DataSet(&attachmentTable).
+		StatementKey("selectByAssignment").
+		Params(saId).
+		Dest(&attachments).
+		Fetch()
+	return attachments, err
+}
+
+func (ss *SurveyStore) GetSurveyAttachments(surveyId uuid.UUID) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	err := ss.DS.Select().
+		DataSet(&attachmentTable).
+		StatementKey("selectBySurvey").
+		Params(surveyId).
+		Dest(&attachments).
+		Fetch()
+	return attachments, err
+}
+
+func (ss *SurveyStore) DeleteAttachment(attachmentId uuid.UUID) error {
+	return ss.DS.Exec(goquery.NoTx, attachmentTable.Statements["delete"], attachmentId)
+}
@@ -0,0 +1,133 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+var errDryRun = errors.New("Dry run complete")
+
+// ExportSurvey reads a complete copy of a survey
+func (ss *SurveyStore) ExportSurvey(surveyId uuid.UUID) (models.SurveyArchive, error) {
+	archive := models.SurveyArchive{
+		Users:       []models.User{},
+		Members:     []models.SurveyMember{},
+		Elements:    []models.SurveyElement{},
+		Assignments: []models.ArchiveAssignment{},
+		Results:     []models.ArchiveResult{},
+		Comments:    []models.Comment{},
+	}
+	var err error
+	archive.Survey, err = ss.GetSurvey(surveyId)
+	if err != nil {
+		return archive, err
+	}
+	for key, dest := range map[string]interface{}{
+		"users":       &archive.Users,
+		"members":     &archive.Members,
+		"elements":    &archive.Elements,
+		"assignments": &archive.Assignments,
+		"results":     &archive.Results,
+		"comments":    &archive.Comments,
+	} {
+		err = ss.DS.Select().
+			DataSet(&surveyBackupTable).
+			StatementKey(key).
+			Params(surveyId).
+			Dest(dest).
+			Fetch()
+		if err != nil {
+			return archive, err
+		}
+	}
+	if archive.Flags, err = ss.GetSurveyFlags(surveyId, nil, ""); err != nil {
+		return archive, err
+	}
+	if archive.Territories, err = ss.GetTerritories(surveyId); err != nil {
+		return archive, err
+	}
+	if archive.Domains, err = ss.GetSurveyDomains(surveyId); err != nil {
+		return archive, err
+	}
+	settings, err := ss.GetSurveySettings(surveyId)
+	if err != nil {
+		return archive, err
+	}
+	archive.Settings = &settings
+	return archive, nil
+}
+
+// ImportSurvey writes an archive as a new survey in a single transaction.  Ids are written as they are
+// in the archive, so archives from another environment should be remapped first.  Users that do not
+// exist are created.  A dry run performs every write and then rolls the transaction back.
+func (ss *SurveyStore) ImportSurvey(archive models.SurveyArchive, dryRun bool) error {
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		pgtx := tx.PgxTx()
+		exec := func(key string, params ...interface{}) {
+			if _, err := pgtx.Exec(context.Background(), surveyBackupTable.Statements[key], params...); err != nil {
+				panic(err)
+			}
+		}
+		s := archive.Survey
+		exec("insertSurvey", s.ID, s.Title, s.Description, s.Active)
+		for _, u := range archive.Users {
+			exec("insertUser", u.UserID, u.Username)
+		}
+		for _, m := range archive.Members {
+			exec("insertMember", m.ID, s.ID, m.UserID, m.MemberRole())
+		}
+		for _, e := range archive.Elements {
+			exec("insertElement", e.ID, s.ID, e.SurveyOrder, e.FD_ID, e.Is_control)
+		}
+		for _, sa := range archive.Assignments {
+			exec("insertAssignment", sa.ID, sa.SEID, sa.Completed, sa.AssignedTo, sa.AssignedAt)
+		}
+		for _, r := range archive.Results {
+			exec("insertResult", r.ID, r.SAID, r.FDID, r.X, r.Y, r.InvalidStructure, r.NoStreetView, r.CBfips, r.OccupancyType,
+				r.Damcat, r.FoundHt, r.Stories, r.SqFt, r.FoundType, r.RsmeansType, r.Quality, r.ConstType, r.Garage, r.RoofStyle,
+				r.Version, r.UpdatedBy, r.UpdatedAt)
+		}
+		if archive.Settings != nil {
+			st := archive.Settings
+			_, err := pgtx.Exec(context.Background(), surveySettingTable.Statements["upsert"],
+				s.ID, st.AssignmentStrategy, st.MaxAssignments, st.DailyCap, st.ControlRate)
+			if err != nil {
+				panic(err)
+			}
+		}
+		for i, d := range archive.Domains {
+			_, err := pgtx.Exec(context.Background(), surveyDomainTable.Statements["insert"], s.ID, d.Column, d.Code, d.Description, i)
+			if err != nil {
+				panic(err)
+			}
+		}
+		for _, t := range archive.Territories {
+			exec("insertTerritory", t.ID, s.ID, t.Name, models.FormatPolygon(t.Polygon), strings.Join(t.BlockGroups, ","))
+			for _, userId := range t.Members {
+				_, err := pgtx.Exec(context.Background(), territoryTable.Statements["insertMember"], t.ID, userId)
+				if err != nil {
+					panic(err)
+				}
+			}
+		}
+		for _, c := range archive.Comments {
+			exec("insertComment", c.ID, s.ID, c.SEID, c.SAID, c.ParentID, c.UserID, c.Body, c.CreatedAt)
+		}
+		for _, f := range archive.Flags {
+			exec("insertFlag", f.ID, s.ID, f.SEID, f.SAID, f.FlagType, f.Note, f.RaisedBy, f.RaisedAt,
+				f.Resolved, f.ResolvedBy, f.ResolvedAt, f.Resolution)
+		}
+		if dryRun {
+			panic(errDryRun)
+		}
+	})
+	if err != nil && err.Error() == errDryRun.Error() {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// CloneSurvey copies a survey and the parts chosen in the clone options into a new survey in a single
+// transaction.  The user making the copy is always an owner of the new survey.
+func (ss *SurveyStore) CloneSurvey(surveyId uuid.UUID, clone models.SurveyClone, userId string) (uuid.UUID, error) {
+	var newId uuid.UUID
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		err := ss.DS.Select().
+			DataSet(&surveyTable).
+			Tx(&tx).
+			StatementKey("clone").
+			Params(surveyId, clone.Title, clone.Description).
+			Dest(&newId).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		pgtx := tx.PgxTx()
+		exec := func(stmt string, params ...interface{}) {
+			if _, err := pgtx.Exec(context.Background(), stmt, params...); err != nil {
+				panic(err)
+			}
+		}
+		if clone.Members != models.CloneMembersNone {
+			exec(surveyMemberTable.Statements["clone"], surveyId, newId, clone.Members == models.CloneMembersAll)
+		}
+		exec(surveyMemberTable.Statements["upsert"], newId, userId, models.RoleOwner)
+		if clone.Elements {
+			exec(surveyElementTable.Statements["clone"], surveyId, newId,
+				clone.Order == models.CloneOrderRandom, clone.Controls == models.CloneControlsKeep)
+			if clone.Controls == models.CloneControlsRandom {
+				exec(surveyElementTable.Statements["randomControls"], newId, clone.ControlCount)
+			}
+		}
+		if clone.Settings {
+			exec(surveySettingTable.Statements["clone"], surveyId, newId)
+			exec(surveyDomainTable.Statements["clone"], surveyId, newId)
+			exec(territoryTable.Statements["clone"], surveyId, newId)
+			exec(territoryTable.Statements["cloneMembers"], surveyId, newId)
+		}
+	})
+	return newId, err
+}
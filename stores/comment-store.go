@@ -0,0 +1,97 @@
+package stores
+
+import (
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+)
+
+// GetSurveyElement returns an element if it belongs to the survey
+func (ss *SurveyStore) GetSurveyElement(surveyId uuid.UUID, seId uuid.UUID) (models.SurveyElement, error) {
+	se := models.SurveyElement{}
+	err := ss.DS.Select().
+		DataSet(&surveyElementTable).
+		StatementKey("selectInSurvey").
+		Params(seId, surveyId).
+		Dest(&se).
+		Fetch()
+	return se, err
+}
+
+func (ss *SurveyStore) InsertComment(comment models.Comment) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&commentTable).
+		StatementKey("insert").
+		Params(comment.SurveyID, comment.SEID, comment.SAID, comment.ParentID, comment.UserID, comment.Body).
+		Dest(&id).
+		Fetch()
+	return id, err
+}
+
+func (ss *SurveyStore) GetComment(commentId uuid.UUID) (models.Comment, error) {
+	comment := models.Comment{}
+	err := ss.DS.Select().
+		DataSet(&commentTable).
+		StatementKey("selectById").
+		Params(commentId).
+		Dest(&comment).
+		Fetch()
+	return comment, err
+}
+
+func (ss *SurveyStore) GetElementComments(seId uuid.UUID) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	err := ss.DS.Select().
+		DataSet(&commentTable).
+		StatementKey("selectByElement").
+		Params(seId).
+		Dest(&comments).
+		Fetch()
+	return comments, err
+}
+
+func (ss *SurveyStore) InsertFlag(flag models.Flag) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&flagTable).
+		StatementKey("insert").
+		Params(flag.SurveyID, flag.SEID, flag.SAID, flag.FlagType, flag.Note, flag.RaisedBy).
+		Dest(&id).
+		Fetch()
+	return id, err
+}
+
+// GetSurveyFlags lists the flags for a survey.  A nil resolved value returns flags in any state
+// and an empty flagType returns flags of every type.
+func (ss *SurveyStore) GetSurveyFlags(surveyId uuid.UUID, resolved *bool, flagType string) ([]models.Flag, error) {
+	flags := []models.Flag{}
+	err := ss.DS.Select().
+		DataSet(&flagTable).
+		StatementKey("select").
+		Params(surveyId, resolved, flagType).
+		Dest(&flags).
+		Fetch()
+	return flags, err
+}
+
+func (ss *SurveyStore) GetElementFlags(seId uuid.UUID) ([]models.Flag, error) {
+	flags := []models.Flag{}
+	err := ss.DS.Select().
+		DataSet(&flagTable).
+		StatementKey("selectByElement").
+		Params(seId).
+		Dest(&flags).
+		Fetch()
+	return flags, err
+}
+
+// ResolveFlag closes an open flag.  Returns NoResults if the flag is not an open flag in the survey.
+func (ss *SurveyStore) ResolveFlag(surveyId uuid.UUID, flagId uuid.UUID, userId string, resolution string) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&flagTable).
+		StatementKey("resolve").
+		Params(userId, resolution, flagId, surveyId).
+		Dest(&id).
+		Fetch()
+}
@@ -0,0 +1,49 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// GetSurveyDictionary returns the structure attribute set with any survey specific
+// domain values applied
+func (ss *SurveyStore) GetSurveyDictionary(surveyId uuid.UUID) ([]models.Attribute, error) {
+	overrides, err := ss.GetSurveyDomains(surveyId)
+	if err != nil {
+		return nil, err
+	}
+	return models.ApplyDomainOverrides(models.StructureAttributes, overrides), nil
+}
+
+// GetSurveyDomains returns the survey specific domain values of a survey
+func (ss *SurveyStore) GetSurveyDomains(surveyId uuid.UUID) ([]models.SurveyDomainValue, error) {
+	domains := []models.SurveyDomainValue{}
+	err := ss.DS.Select().
+		DataSet(&surveyDomainTable).
+		StatementKey("select").
+		Params(surveyId).
+		Dest(&domains).
+		Fetch()
+	return domains, err
+}
+
+// SetSurveyDomain replaces the survey specific values for a single attribute, keeping their
+// order.  An empty values list restores the default domain.
+func (ss *SurveyStore) SetSurveyDomain(surveyId uuid.UUID, column string, values []models.DomainValue) error {
+	return ss.DS.Transaction(func(tx goquery.Tx) {
+		pgtx := tx.PgxTx()
+		_, err := pgtx.Exec(context.Background(), surveyDomainTable.Statements["delete"], surveyId, column)
+		if err != nil {
+			panic(err)
+		}
+		for i, v := range values {
+			_, err = pgtx.Exec(context.Background(), surveyDomainTable.Statements["insert"], surveyId, column, v.Code, v.Description, i)
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// GetSurveyGroups lists the identity provider groups bound to a survey with the number of members each
+// has granted
+func (ss *SurveyStore) GetSurveyGroups(surveyId uuid.UUID) ([]models.SurveyGroup, error) {
+	groups := []models.SurveyGroup{}
+	err := ss.DS.Select().
+		DataSet(&surveyGroupTable).
+		StatementKey("select").
+		Params(surveyId).
+		Dest(&groups).
+		Fetch()
+	return groups, err
+}
+
+// UpsertSurveyGroup binds a group to a survey, or changes the role of a bound group along with the
+// members it has granted.  Users carrying the group are granted membership on their next request.  Returns
+// ErrLastOwner if the new role would leave the survey without an owner.
+func (ss *SurveyStore) UpsertSurveyGroup(group models.SurveyGroup) error {
+	err := ss.keepingAnOwner(group.SurveyID, func(tx goquery.Tx) error {
+		pgtx := tx.PgxTx()
+		for _, key := range []string{"upsert", "updateMembers"} {
+			_, err := pgtx.Exec(context.Background(), surveyGroupTable.Statements[key], group.SurveyID, group.Group, group.Role)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		invalidateProfiles()
+	}
+	invalidateSurveyRoles(group.SurveyID)
+	return err
+}
+
+// DeleteSurveyGroup unbinds a group from a survey and removes the memberships it granted, settling each
+// member's open assignments as the removal describes.  Returns NoResults if the group is not bound to the
+// survey and ErrLastOwner if the group granted the survey's last owner.  The attachments of released
+// assignments are returned so their content can be deleted.
+func (ss *SurveyStore) DeleteSurveyGroup(surveyId uuid.UUID, group string, removal models.MemberRemoval) (models.MemberRemovalResult, error) {
+	result := models.MemberRemovalResult{}
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		var name string
+		err := ss.DS.Select().
+			DataSet(&surveyGroupTable).
+			Tx(&tx).
+			StatementKey("delete").
+			Params(surveyId, group).
+			Dest(&name).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		members := []string{}
+		err = ss.DS.Select().
+			DataSet(&surveyGroupTable).
+			Tx(&tx).
+			StatementKey("members").
+			Params(surveyId, group).
+			Dest(&members).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		for _, userId := range members {
+			removed, err := ss.removeMember(tx, surveyId, userId, removal)
+			if err != nil {
+				panic(err)
+			}
+			result.Released += removed.Released
+			result.Kept += removed.Kept
+			result.Attachments = append(result.Attachments, removed.Attachments...)
+		}
+	})
+	if err != nil && err.Error() == ErrLastOwner.Error() {
+		err = ErrLastOwner
+	}
+	invalidateSurveyRoles(surveyId)
+	return result, err
+}
+
+// SyncGroupMemberships brings a user's group granted memberships in line with the groups in their token.
+// Memberships granted by groups the user no longer carries are removed, keeping their open assignments,
+// and bound groups they carry are granted, taking the most privileged role when several groups bind the
+// same survey.  A membership that is the last owner of its survey is kept.  Memberships added directly
+// are never changed.
+func (ss *SurveyStore) SyncGroupMemberships(userId string, groups []string) error {
+	if groups == nil {
+		groups = []string{}
+	}
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		surveys := []uuid.UUID{}
+		err := ss.DS.Select().
+			DataSet(&surveyGroupTable).
+			Tx(&tx).
+			StatementKey("syncStale").
+			Params(userId, groups).
+			Dest(&surveys).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		keep := models.MemberRemoval{Assignments: models.KeepAssignments}
+		for _, surveyId := range surveys {
+			if _, err = ss.removeMember(tx, surveyId, userId, keep); err != nil && err != ErrLastOwner {
+				panic(err)
+			}
+		}
+		if _, err = tx.PgxTx().Exec(context.Background(), surveyGroupTable.Statements["syncAdd"], userId, groups); err != nil {
+			panic(err)
+		}
+	})
+	invalidateUserRoles(userId)
+	return err
+}
@@ -0,0 +1,47 @@
+package stores
+
+import (
+	"log"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/usace/goquery"
+)
+
+// ReserveIdempotencyKey records that a request with the key is in progress.  Returns false with the
+// stored response if the user has already used the key.  Keys expire after 24 hours.
+func (ss *SurveyStore) ReserveIdempotencyKey(userId string, key string, requestHash string) (bool, models.IdempotentResponse, error) {
+	stored := models.IdempotentResponse{}
+	if err := ss.DS.Exec(goquery.NoTx, idempotencyTable.Statements["expire"]); err != nil {
+		log.Printf("Error expiring idempotency keys: %s", err)
+	}
+	var reserved string
+	err := ss.DS.Select().
+		DataSet(&idempotencyTable).
+		StatementKey("reserve").
+		Params(userId, key, requestHash).
+		Dest(&reserved).
+		Fetch()
+	if err == nil {
+		return true, stored, nil
+	}
+	if err.Error() != NoResults {
+		return false, stored, err
+	}
+	err = ss.DS.Select().
+		DataSet(&idempotencyTable).
+		StatementKey("select").
+		Params(userId, key).
+		Dest(&stored).
+		Fetch()
+	return false, stored, err
+}
+
+func (ss *SurveyStore) CompleteIdempotencyKey(userId string, key string, response models.IdempotentResponse) error {
+	return ss.DS.Exec(goquery.NoTx, idempotencyTable.Statements["complete"],
+		response.StatusCode, response.Response, response.ContentType, response.ETag, userId, key)
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed so that it can be retried
+func (ss *SurveyStore) ReleaseIdempotencyKey(userId string, key string) error {
+	return ss.DS.Exec(goquery.NoTx, idempotencyTable.Statements["release"], userId, key)
+}
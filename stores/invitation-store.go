@@ -0,0 +1,66 @@
+package stores
+
+import (
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// InsertInvitation records a pending invitation and returns its id
+func (ss *SurveyStore) InsertInvitation(invitation models.Invitation) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&invitationTable).
+		StatementKey("insert").
+		Params(invitation.SurveyID, invitation.Email, invitation.Subject, invitation.Role, invitation.InvitedBy, invitation.ExpiresInDays).
+		Dest(&id).
+		Fetch()
+	if err == nil {
+		invalidateProfiles()
+	}
+	return id, err
+}
+
+// GetInvitations lists the invitations of a survey, newest first.  Status is one of pending, accepted,
+// expired, or revoked; an empty status returns every invitation.
+func (ss *SurveyStore) GetInvitations(surveyId uuid.UUID, status string) ([]models.Invitation, error) {
+	invitations := []models.Invitation{}
+	err := ss.DS.Select().
+		DataSet(&invitationTable).
+		StatementKey("select").
+		Params(surveyId, status).
+		Dest(&invitations).
+		Fetch()
+	return invitations, err
+}
+
+func (ss *SurveyStore) GetInvitation(surveyId uuid.UUID, invitationId uuid.UUID) (models.Invitation, error) {
+	invitation := models.Invitation{}
+	err := ss.DS.Select().
+		DataSet(&invitationTable).
+		StatementKey("selectById").
+		Params(surveyId, invitationId).
+		Dest(&invitation).
+		Fetch()
+	return invitation, err
+}
+
+// RevokeInvitation withdraws a pending invitation.  Returns NoResults if the invitation is not pending.
+func (ss *SurveyStore) RevokeInvitation(surveyId uuid.UUID, invitationId uuid.UUID) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&invitationTable).
+		StatementKey("revoke").
+		Params(surveyId, invitationId).
+		Dest(&id).
+		Fetch()
+}
+
+// AcceptInvitations converts the pending invitations matching a user's subject or email into survey
+// memberships.  Owner and coordinator invitations only match the subject.  Existing memberships are
+// left unchanged.
+func (ss *SurveyStore) AcceptInvitations(userId string, email string) error {
+	err := ss.DS.Exec(goquery.NoTx, invitationTable.Statements["accept"], userId, email)
+	invalidateUserRoles(userId)
+	return err
+}
@@ -0,0 +1,53 @@
+package stores
+
+import (
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+)
+
+func (ss *SurveyStore) InsertNewStructure(ns models.NewStructure) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&newStructureTable).
+		StatementKey("insert").
+		Params(ns.SurveyID, ns.SubmittedBy, ns.X, ns.Y, ns.CBfips, ns.OccupancyType, ns.Damcat, ns.FoundHt, ns.Stories, ns.SqFt,
+			ns.FoundType, ns.RsmeansType, ns.Quality, ns.ConstType, ns.Garage, ns.RoofStyle).
+		Dest(&id).
+		Fetch()
+	return id, err
+}
+
+func (ss *SurveyStore) GetNewStructure(surveyId uuid.UUID, id uuid.UUID) (models.NewStructure, error) {
+	ns := models.NewStructure{}
+	err := ss.DS.Select().
+		DataSet(&newStructureTable).
+		StatementKey("selectById").
+		Params(id, surveyId).
+		Dest(&ns).
+		Fetch()
+	return ns, err
+}
+
+// GetNewStructures lists the surveyor added structures for a survey.  An empty status returns
+// structures in every review state.
+func (ss *SurveyStore) GetNewStructures(surveyId uuid.UUID, status string) ([]models.NewStructure, error) {
+	structures := []models.NewStructure{}
+	err := ss.DS.Select().
+		DataSet(&newStructureTable).
+		StatementKey("select").
+		Params(surveyId, status).
+		Dest(&structures).
+		Fetch()
+	return structures, err
+}
+
+// ReviewNewStructure records an owner decision.  Returns NoResults if the structure is not in the survey.
+func (ss *SurveyStore) ReviewNewStructure(surveyId uuid.UUID, id uuid.UUID, status string, userId string, note string) error {
+	var updated uuid.UUID
+	return ss.DS.Select().
+		DataSet(&newStructureTable).
+		StatementKey("review").
+		Params(status, userId, note, id, surveyId).
+		Dest(&updated).
+		Fetch()
+}
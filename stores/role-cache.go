@@ -0,0 +1,115 @@
+package stores
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+)
+
+// maxCachedRoles bounds the role cache.  Expired entries are pruned once it is reached.
+const maxCachedRoles = 10000
+
+type memberKey struct {
+	surveyId uuid.UUID
+	userId   string
+}
+
+type cachedRole struct {
+	role    string
+	expires time.Time
+}
+
+// roleCache holds recent survey member role lookups.  Every store method that changes survey_member
+// invalidates the affected entries, so the TTL only bounds staleness across server instances.  The
+// generation is advanced by every invalidation so a lookup that races a change is not cached.
+var roleCache = struct {
+	sync.Mutex
+	ttl        time.Duration
+	generation uint64
+	roles      map[memberKey]cachedRole
+}{ttl: 30 * time.Second, roles: map[memberKey]cachedRole{}}
+
+var roleCacheHits, roleCacheMisses uint64
+
+// SetRoleCacheTTL sets how long member roles are cached.  A zero ttl disables the cache.
+func SetRoleCacheTTL(ttl time.Duration) {
+	roleCache.Lock()
+	roleCache.ttl = ttl
+	roleCache.roles = map[memberKey]cachedRole{}
+	roleCache.generation++
+	roleCache.Unlock()
+}
+
+// cachedMemberRole returns a cached role and the cache generation to pass to cacheMemberRole on a miss
+func cachedMemberRole(key memberKey) (string, bool, uint64) {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+	if cached, ok := roleCache.roles[key]; ok && time.Now().Before(cached.expires) {
+		atomic.AddUint64(&roleCacheHits, 1)
+		return cached.role, true, roleCache.generation
+	}
+	atomic.AddUint64(&roleCacheMisses, 1)
+	return "", false, roleCache.generation
+}
+
+func cacheMemberRole(key memberKey, role string, generation uint64) {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+	if roleCache.ttl <= 0 || generation != roleCache.generation {
+		return
+	}
+	now := time.Now()
+	if len(roleCache.roles) >= maxCachedRoles {
+		for k, cached := range roleCache.roles {
+			if !now.Before(cached.expires) {
+				delete(roleCache.roles, k)
+			}
+		}
+		if len(roleCache.roles) >= maxCachedRoles {
+			roleCache.roles = map[memberKey]cachedRole{}
+		}
+	}
+	roleCache.roles[key] = cachedRole{role: role, expires: now.Add(roleCache.ttl)}
+}
+
+func invalidateRoles(match func(memberKey) bool) {
+	roleCache.Lock()
+	defer roleCache.Unlock()
+	roleCache.generation++
+	for k := range roleCache.roles {
+		if match(k) {
+			delete(roleCache.roles, k)
+		}
+	}
+}
+
+func invalidateMemberRole(surveyId uuid.UUID, userId string) {
+	invalidateRoles(func(k memberKey) bool { return k.surveyId == surveyId && k.userId == userId })
+}
+
+func invalidateSurveyRoles(surveyId uuid.UUID) {
+	invalidateRoles(func(k memberKey) bool { return k.surveyId == surveyId })
+}
+
+func invalidateUserRoles(userId string) {
+	invalidateRoles(func(k memberKey) bool { return k.userId == userId })
+}
+
+// GetRoleCacheStats reports the hits and misses of the member role cache since startup
+func GetRoleCacheStats() models.RoleCacheStats {
+	stats := models.RoleCacheStats{
+		Hits:   atomic.LoadUint64(&roleCacheHits),
+		Misses: atomic.LoadUint64(&roleCacheMisses),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	roleCache.Lock()
+	stats.Entries = len(roleCache.roles)
+	stats.TTL = roleCache.ttl.String()
+	roleCache.Unlock()
+	return stats
+}
@@ -0,0 +1,103 @@
+package stores
+
+import (
+	"testing"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// lookup returns a role through the cache, calling the loader on a miss as GetMemberRole does
+func lookup(key memberKey, load func() string) (string, bool) {
+	role, ok, generation := cachedMemberRole(key)
+	if ok {
+		return role, true
+	}
+	role = load()
+	cacheMemberRole(key, role, generation)
+	return role, false
+}
+
+func TestRoleCacheHitAndMiss(t *testing.T) {
+	SetRoleCacheTTL(time.Minute)
+	defer SetRoleCacheTTL(30 * time.Second)
+	key := memberKey{uuid.New(), "987654"}
+	before := GetRoleCacheStats()
+
+	role, hit := lookup(key, func() string { return models.RoleOwner })
+	assert.False(t, hit)
+	assert.Equal(t, models.RoleOwner, role)
+	role, hit = lookup(key, func() string { return models.RoleViewer })
+	assert.True(t, hit)
+	assert.Equal(t, models.RoleOwner, role)
+
+	stats := GetRoleCacheStats()
+	assert.Equal(t, before.Hits+1, stats.Hits)
+	assert.Equal(t, before.Misses+1, stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, "1m0s", stats.TTL)
+}
+
+func TestRoleCacheInvalidation(t *testing.T) {
+	SetRoleCacheTTL(time.Minute)
+	defer SetRoleCacheTTL(30 * time.Second)
+	survey, other := uuid.New(), uuid.New()
+	keys := []memberKey{{survey, "987654"}, {survey, "987655"}, {other, "987654"}, {other, "987655"}}
+	fill := func() {
+		for _, key := range keys {
+			lookup(key, func() string { return models.RoleSurveyor })
+		}
+	}
+	cached := func() []bool {
+		hits := []bool{}
+		for _, key := range keys {
+			_, ok, _ := cachedMemberRole(key)
+			hits = append(hits, ok)
+		}
+		return hits
+	}
+
+	fill()
+	invalidateMemberRole(survey, "987654")
+	assert.Equal(t, []bool{false, true, true, true}, cached())
+
+	fill()
+	invalidateSurveyRoles(survey)
+	assert.Equal(t, []bool{false, false, true, true}, cached())
+
+	fill()
+	invalidateUserRoles("987655")
+	assert.Equal(t, []bool{true, false, true, false}, cached())
+}
+
+func TestRoleCacheRacingLookup(t *testing.T) {
+	SetRoleCacheTTL(time.Minute)
+	defer SetRoleCacheTTL(30 * time.Second)
+	key := memberKey{uuid.New(), "987654"}
+
+	// the lookup reads the old role, then a change commits and invalidates before it is cached
+	_, hit, generation := cachedMemberRole(key)
+	assert.False(t, hit)
+	invalidateMemberRole(key.surveyId, key.userId)
+	cacheMemberRole(key, models.RoleOwner, generation)
+
+	_, hit, _ = cachedMemberRole(key)
+	assert.False(t, hit, "a lookup that raced an invalidation is not cached")
+	role, hit := lookup(key, func() string { return models.RoleViewer })
+	assert.False(t, hit)
+	assert.Equal(t, models.RoleViewer, role)
+}
+
+func TestRoleCacheDisabled(t *testing.T) {
+	SetRoleCacheTTL(0)
+	defer SetRoleCacheTTL(30 * time.Second)
+	key := memberKey{uuid.New(), "987654"}
+
+	lookup(key, func() string { return models.RoleOwner })
+	role, hit := lookup(key, func() string { return models.RoleViewer })
+	assert.False(t, hit)
+	assert.Equal(t, models.RoleViewer, role)
+	assert.Equal(t, 0, GetRoleCacheStats().Entries)
+}
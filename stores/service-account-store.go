@@ -0,0 +1,153 @@
+package stores
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+var ErrServiceAccountExists = errors.New("A service account with this name already exists")
+
+// InsertServiceAccount creates a service account.  Returns ErrServiceAccountExists if the name is taken.
+func (ss *SurveyStore) InsertServiceAccount(account models.ServiceAccount) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&serviceAccountTable).
+		StatementKey("insert").
+		Params(account.Name, account.Description, account.CreatedBy).
+		Dest(&id).
+		Fetch()
+	if err != nil && err.Error() == NoResults {
+		err = ErrServiceAccountExists
+	}
+	return id, err
+}
+
+// GetServiceAccounts lists the service accounts ordered by name
+func (ss *SurveyStore) GetServiceAccounts() ([]models.ServiceAccount, error) {
+	accounts := []models.ServiceAccount{}
+	err := ss.DS.Select().
+		DataSet(&serviceAccountTable).
+		StatementKey("select").
+		Dest(&accounts).
+		Fetch()
+	return accounts, err
+}
+
+func (ss *SurveyStore) GetServiceAccount(accountId uuid.UUID) (models.ServiceAccount, error) {
+	account := models.ServiceAccount{}
+	err := ss.DS.Select().
+		DataSet(&serviceAccountTable).
+		StatementKey("selectById").
+		Params(accountId).
+		Dest(&account).
+		Fetch()
+	return account, err
+}
+
+// SetServiceAccountActive enables or disables every key of a service account.  Returns NoResults if the
+// account does not exist.
+func (ss *SurveyStore) SetServiceAccountActive(accountId uuid.UUID, active bool) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&serviceAccountTable).
+		StatementKey("setActive").
+		Params(accountId, active).
+		Dest(&id).
+		Fetch()
+}
+
+// InsertAPIKey records a key by its prefix and hash along with the surveys it is scoped to
+func (ss *SurveyStore) InsertAPIKey(key models.APIKey, hash string) (uuid.UUID, error) {
+	surveys := make([]string, len(key.Surveys))
+	for i, s := range key.Surveys {
+		surveys[i] = s.String()
+	}
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&apiKeyTable).
+		StatementKey("insert").
+		Params(key.AccountID, key.Name, key.Prefix, hash, strings.Join(key.Permissions, ","), key.CreatedBy,
+			key.ExpiresInDays, strings.Join(surveys, ",")).
+		Dest(&id).
+		Fetch()
+	return id, err
+}
+
+// GetAPIKeys lists the keys of a service account, newest first, including revoked and expired keys
+func (ss *SurveyStore) GetAPIKeys(accountId uuid.UUID) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := ss.DS.Select().
+		DataSet(&apiKeyTable).
+		StatementKey("select").
+		Params(accountId).
+		Dest(&keys).
+		Fetch()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		if err := keys[i].Expand(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func (ss *SurveyStore) GetAPIKey(accountId uuid.UUID, keyId uuid.UUID) (models.APIKey, error) {
+	key := models.APIKey{}
+	err := ss.DS.Select().
+		DataSet(&apiKeyTable).
+		StatementKey("selectById").
+		Params(accountId, keyId).
+		Dest(&key).
+		Fetch()
+	if err != nil {
+		return key, err
+	}
+	return key, key.Expand()
+}
+
+// RevokeAPIKey permanently disables a key.  Returns NoResults if the key has already been revoked.
+func (ss *SurveyStore) RevokeAPIKey(accountId uuid.UUID, keyId uuid.UUID) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&apiKeyTable).
+		StatementKey("revoke").
+		Params(accountId, keyId).
+		Dest(&id).
+		Fetch()
+}
+
+// AuthenticateAPIKey returns the key matching a presented key.  Returns ErrInvalidAPIKey if the key is
+// unknown, revoked, or expired, or its service account is disabled.  The last used time of the key is
+// recorded at most once a minute.
+func (ss *SurveyStore) AuthenticateAPIKey(presented string) (models.APIKey, error) {
+	key := models.APIKey{}
+	prefix, ok := models.APIKeyPrefix(presented)
+	if !ok {
+		return key, ErrInvalidAPIKey
+	}
+	err := ss.DS.Select().
+		DataSet(&apiKeyTable).
+		StatementKey("authenticate").
+		Params(prefix, models.HashAPIKey(presented)).
+		Dest(&key).
+		Fetch()
+	if err != nil {
+		if err.Error() == NoResults {
+			return key, ErrInvalidAPIKey
+		}
+		return key, err
+	}
+	if err := ss.DS.Exec(goquery.NoTx, apiKeyTable.Statements["touch"], key.ID); err != nil {
+		log.Printf("Error recording use of API key %s: %s", key.Prefix, err)
+	}
+	return key, key.Expand()
+}
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strings"
 
@@ -13,6 +14,12 @@ import (
 
 var NoResults string = "no rows in result set"
 
+var ErrVersionConflict = errors.New("Survey result has been modified")
+
+var ErrLastOwner = errors.New("A survey must keep at least one owner")
+
+var ErrAssignmentSubmitted = errors.New("Assignment has already been submitted")
+
 type SurveyStore struct {
 	DS goquery.DataStore
 }
@@ -30,10 +37,6 @@ func CreateSurveyStore(appConfig *config.Config) (*SurveyStore, error) {
 	return &ss, nil
 }
 
-func (ss *SurveyStore) AddUser(user models.User) error {
-	return ss.DS.Exec(goquery.NoTx, usersTable.Statements["insert"], user.UserID, user.Username)
-}
-
 func (ss *SurveyStore) GetSurveysforUser(userId string) (*[]models.Survey, error) {
 	surveys := []models.Survey{}
 	err := ss.DS.Select().
@@ -117,18 +120,211 @@ func (ss *SurveyStore) UpdateSurvey(survey models.Survey) error {
 	return err
 }
 
+// UpsertSurveyMember adds a member or changes their role.  Demoting the last owner returns ErrLastOwner.
 func (ss *SurveyStore) UpsertSurveyMember(member models.SurveyMember) error {
-	err := ss.DS.Exec(goquery.NoTx, surveyMemberTable.Statements["upsert"], member.SurveyID, member.UserID, member.IsOwner)
+	err := ss.keepingAnOwner(member.SurveyID, func(tx goquery.Tx) error {
+		_, err := tx.PgxTx().Exec(context.Background(), surveyMemberTable.Statements["upsert"], member.SurveyID, member.UserID, member.MemberRole())
+		return err
+	})
+	invalidateMemberRole(member.SurveyID, member.UserID)
 	return err
 }
 
-func (ss *SurveyStore) RemoveSurveyMember(memberId uuid.UUID) error {
-	err := ss.DS.Exec(goquery.NoTx, surveyMemberTable.Statements["remove"], memberId)
+// keepingAnOwner runs changes to a survey's members in a transaction holding the owner rows and fails
+// them with ErrLastOwner if a survey that had an owner would be left without one
+func (ss *SurveyStore) keepingAnOwner(surveyId uuid.UUID, changes func(tx goquery.Tx) error) error {
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		owners := []string{}
+		err := ss.DS.Select().
+			DataSet(&surveyMemberTable).
+			Tx(&tx).
+			StatementKey("lockOwners").
+			Params(surveyId).
+			Dest(&owners).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		if err = changes(tx); err != nil {
+			panic(err)
+		}
+		var remaining int
+		if err = tx.PgxTx().QueryRow(context.Background(), surveyMemberTable.Statements["ownerCount"], surveyId).Scan(&remaining); err != nil {
+			panic(err)
+		}
+		if len(owners) > 0 && remaining == 0 {
+			panic(ErrLastOwner)
+		}
+	})
+	if err != nil && err.Error() == ErrLastOwner.Error() {
+		err = ErrLastOwner
+	}
 	return err
 }
 
-func (ss *SurveyStore) RemoveMemberFromSurvey(memberId string, surveyId uuid.UUID) error {
-	err := ss.DS.Exec(goquery.NoTx, surveyMemberTable.Statements["removeFromSurvey"], memberId, surveyId)
+func (ss *SurveyStore) GetSurveyMember(memberId uuid.UUID) (models.SurveyMember, error) {
+	member := models.SurveyMember{}
+	err := ss.DS.Select().
+		DataSet(&surveyMemberTable).
+		StatementKey("selectById").
+		Params(memberId).
+		Dest(&member).
+		Fetch()
+	return member, err
+}
+
+// OpenAssignmentCount returns the number of incomplete assignments a user holds in a survey
+func (ss *SurveyStore) OpenAssignmentCount(surveyId uuid.UUID, userId string) (int, error) {
+	var open int
+	err := ss.DS.Select().
+		DataSet(&memberRemovalTable).
+		StatementKey("openCount").
+		Params(surveyId, userId).
+		Dest(&open).
+		Fetch()
+	return open, err
+}
+
+// RemoveSurveyMember removes a user's membership in one survey, along with their territory bindings, and
+// settles their open assignments as the removal describes.  Removing the last owner returns ErrLastOwner
+// and removing a user that is not a member returns NoResults.  The attachments of released assignments
+// are returned so their content can be deleted.
+func (ss *SurveyStore) RemoveSurveyMember(surveyId uuid.UUID, userId string, removal models.MemberRemoval) (models.MemberRemovalResult, error) {
+	result := models.MemberRemovalResult{}
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		var err error
+		if result, err = ss.removeMember(tx, surveyId, userId, removal); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil && err.Error() == ErrLastOwner.Error() {
+		err = ErrLastOwner
+	}
+	invalidateMemberRole(surveyId, userId)
+	return result, err
+}
+
+// removeMember removes a member within tx, settling their open assignments as the removal says.  Returns
+// ErrLastOwner before changing anything if the member is the survey's last owner.
+func (ss *SurveyStore) removeMember(tx goquery.Tx, surveyId uuid.UUID, userId string, removal models.MemberRemoval) (models.MemberRemovalResult, error) {
+	result := models.MemberRemovalResult{}
+	var role string
+	err := ss.DS.Select().
+		DataSet(&surveyMemberTable).
+		Tx(&tx).
+		StatementKey("role").
+		Params(surveyId, userId).
+		Dest(&role).
+		Fetch()
+	if err != nil {
+		return result, err
+	}
+	if role == models.RoleOwner {
+		owners := []string{}
+		err = ss.DS.Select().
+			DataSet(&surveyMemberTable).
+			Tx(&tx).
+			StatementKey("lockOwners").
+			Params(surveyId).
+			Dest(&owners).
+			Fetch()
+		if err != nil {
+			return result, err
+		}
+		if len(owners) <= 1 {
+			return result, ErrLastOwner
+		}
+	}
+	pgtx := tx.PgxTx()
+	for _, stmt := range []string{surveyMemberTable.Statements["remove"], memberRemovalTable.Statements["territories"]} {
+		if _, err = pgtx.Exec(context.Background(), stmt, surveyId, userId); err != nil {
+			return result, err
+		}
+	}
+	switch removal.Assignments {
+	case models.ReassignAssignments:
+		tag, err := pgtx.Exec(context.Background(), memberRemovalTable.Statements["reassign"], surveyId, userId, removal.ReassignTo)
+		if err != nil {
+			return result, err
+		}
+		result.Reassigned = int(tag.RowsAffected())
+		fallthrough
+	case models.ReleaseAssignments:
+		err = ss.DS.Select().
+			DataSet(&memberRemovalTable).
+			Tx(&tx).
+			StatementKey("attachments").
+			Params(surveyId, userId).
+			Dest(&result.Attachments).
+			Fetch()
+		if err != nil {
+			return result, err
+		}
+		for _, key := range []string{"releaseAttachments", "releaseResults", "releaseComments", "releaseFlags"} {
+			if _, err = pgtx.Exec(context.Background(), memberRemovalTable.Statements[key], surveyId, userId); err != nil {
+				return result, err
+			}
+		}
+		tag, err := pgtx.Exec(context.Background(), memberRemovalTable.Statements["release"], surveyId, userId)
+		if err != nil {
+			return result, err
+		}
+		result.Released = int(tag.RowsAffected())
+	default:
+		err = ss.DS.Select().
+			DataSet(&memberRemovalTable).
+			Tx(&tx).
+			StatementKey("openCount").
+			Params(surveyId, userId).
+			Dest(&result.Kept).
+			Fetch()
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// KnownUsers returns the subset of user ids that exist
+func (ss *SurveyStore) KnownUsers(userIds []string) (map[string]bool, error) {
+	known := []string{}
+	err := ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("known").
+		Params(userIds).
+		Dest(&known).
+		Fetch()
+	users := map[string]bool{}
+	for _, u := range known {
+		users[u] = true
+	}
+	return users, err
+}
+
+// ImportMembers applies a planned member import in a single transaction.  Members are added or updated
+// and emails are invited with the default expiry.  Imports that would leave the survey without an owner
+// return ErrLastOwner.
+func (ss *SurveyStore) ImportMembers(surveyId uuid.UUID, rows []models.MemberImportRow, invitedBy string) error {
+	err := ss.keepingAnOwner(surveyId, func(tx goquery.Tx) error {
+		pgtx := tx.PgxTx()
+		for _, row := range rows {
+			var err error
+			if row.Action == models.ImportInvite {
+				_, err = pgtx.Exec(context.Background(), invitationTable.Statements["insert"],
+					surveyId, row.User, "", row.Role, invitedBy, models.DefaultInvitationDays)
+			} else {
+				_, err = pgtx.Exec(context.Background(), surveyMemberTable.Statements["upsert"], surveyId, row.User, row.Role)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		invalidateProfiles()
+	}
+	invalidateSurveyRoles(surveyId)
 	return err
 }
 
@@ -145,15 +341,6 @@ func (ss SurveyStore) InsertSurveyElements(elements *[]models.SurveyElement) err
 	return err
 }
 
-func (ss *SurveyStore) AssignSurvey(userId string, seId uuid.UUID) (uuid.UUID, error) {
-	var saId uuid.UUID
-	err := ss.DS.Select(surveyAssignmentTable.Statements["assignSurvey"]).
-		Params(seId, userId).
-		Dest(&saId).
-		Fetch()
-	return saId, err
-}
-
 func (ss SurveyStore) InsertSurveyAssignments(assignments *[]models.SurveyAssignment) error {
 	err := ss.DS.Insert(&surveyAssignmentTable).
 		Records(assignments).
@@ -174,20 +361,6 @@ func (ss *SurveyStore) GetReport(surveyId uuid.UUID) ([]models.SurveyResult, err
 	return s, err
 }
 
-func (ss *SurveyStore) GetAssignmentInfo(userId string, surveyId uuid.UUID) (models.AssignmentInfo, error) {
-	ai := models.AssignmentInfo{}
-	err := ss.DS.Select(surveyAssignmentTable.Statements["assignmentInfo"]).
-		Params(userId, surveyId).
-		Dest(&ai).
-		Fetch()
-
-	if err != nil && err.Error() == NoResults {
-		err = nil
-	}
-
-	return ai, err
-}
-
 func (ss *SurveyStore) GetFirstSurveyInEvent(surveyId uuid.UUID) (uuid.UUID, error) {
 	var firstSurvey uuid.UUID
 	err := ss.DS.Select("select id from survey_element where survey_order=(select min(survey_order) from survey_element where survey_event_id=$1)").
@@ -225,13 +398,27 @@ func (ss *SurveyStore) GetStructure(seId uuid.UUID, saId uuid.UUID) (models.Surv
 	return s, err //return survey from survey_result
 }
 
-func (ss *SurveyStore) SaveSurvey(survey *models.SurveyStructure) error {
+// SaveSurvey stores a survey result and completes the assignment.  When expectedVersion is set the result
+// is only updated if its current version matches, otherwise ErrVersionConflict is returned.  A completed
+// assignment is only overwritten when resubmit is set, otherwise ErrAssignmentSubmitted is returned.
+// Returns the new result version.
+func (ss *SurveyStore) SaveSurvey(survey *models.SurveyStructure, userId string, expectedVersion *int, resubmit bool) (int, error) {
+	var version int
 	err := ss.DS.Transaction(func(tx goquery.Tx) {
 		pgtx := tx.PgxTx()
-		_, txerr := pgtx.Exec(context.Background(), resultTable.Statements["upsertSurveyStructure"],
+		var completed bool
+		txerr := pgtx.QueryRow(context.Background(), surveyAssignmentTable.Statements["lockAssignment"], survey.SAID).Scan(&completed)
+		if txerr != nil {
+			panic(txerr)
+		}
+		if completed && !resubmit {
+			panic(ErrAssignmentSubmitted)
+		}
+		txerr = pgtx.QueryRow(context.Background(), resultTable.Statements["upsertSurveyStructure"],
 			survey.SAID, survey.FDID, survey.X, survey.Y, survey.InvalidStructure, survey.NoStreetView,
 			survey.CBfips, survey.OccupancyType, survey.Damcat, survey.FoundHt, survey.Stories, survey.SqFt,
-			survey.FoundType, survey.RsmeansType, survey.Quality, survey.ConstType, survey.Garage, survey.RoofStyle)
+			survey.FoundType, survey.RsmeansType, survey.Quality, survey.ConstType, survey.Garage, survey.RoofStyle,
+			userId, expectedVersion).Scan(&version)
 		if txerr != nil {
 			panic(txerr)
 		}
@@ -240,31 +427,106 @@ func (ss *SurveyStore) SaveSurvey(survey *models.SurveyStructure) error {
 			panic(txerr)
 		}
 	})
-	return err
+	if err != nil {
+		switch err.Error() {
+		case NoResults:
+			err = ErrVersionConflict
+		case ErrAssignmentSubmitted.Error():
+			err = ErrAssignmentSubmitted
+		}
+	}
+	return version, err
 }
 
-func (ss *SurveyStore) IsOwner(surveyId uuid.UUID, userId string) bool {
-	var owner int
-	err := ss.DS.Select("select count(*) as owner from survey_member where survey_id=$1 and user_id=$2 and is_owner=true").
-		Params(surveyId, userId).
-		Dest(&owner).
+// GetMemberAssignments pages through the assignments made to a user in a survey.  Status is one of
+// open, draft, or completed; an empty status returns every assignment.
+func (ss *SurveyStore) GetMemberAssignments(userId string, surveyId uuid.UUID, status string, rows int, page int) ([]models.MemberAssignment, error) {
+	assignments := []models.MemberAssignment{}
+	err := ss.DS.Select().
+		DataSet(&surveyAssignmentTable).
+		StatementKey("memberAssignments").
+		Params(userId, surveyId, status, rows, rows*page).
+		Dest(&assignments).
+		Fetch()
+	return assignments, err
+}
+
+// ReopenAssignment marks a completed assignment as incomplete so its result can be corrected.
+// Returns NoResults if the assignment is not complete.
+func (ss *SurveyStore) ReopenAssignment(saId uuid.UUID) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&surveyAssignmentTable).
+		StatementKey("reopenAssignment").
+		Params(saId).
+		Dest(&id).
 		Fetch()
+}
+
+// SaveDraft stores the current values of a survey result without completing the assignment.  The assignment
+// is locked so a draft cannot overwrite a result submitted concurrently; ErrAssignmentSubmitted is returned
+// once the assignment is complete.  Versioning follows SaveSurvey.
+func (ss *SurveyStore) SaveDraft(survey *models.SurveyStructure, userId string, expectedVersion *int) (int, error) {
+	var version int
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		pgtx := tx.PgxTx()
+		var completed bool
+		txerr := pgtx.QueryRow(context.Background(), surveyAssignmentTable.Statements["lockAssignment"], survey.SAID).Scan(&completed)
+		if txerr != nil {
+			panic(txerr)
+		}
+		if completed {
+			panic(ErrAssignmentSubmitted)
+		}
+		txerr = pgtx.QueryRow(context.Background(), resultTable.Statements["upsertDraft"],
+			survey.SAID, survey.FDID, survey.X, survey.Y, survey.InvalidStructure, survey.NoStreetView,
+			survey.CBfips, survey.OccupancyType, survey.Damcat, survey.FoundHt, survey.Stories, survey.SqFt,
+			survey.FoundType, survey.RsmeansType, survey.Quality, survey.ConstType, survey.Garage, survey.RoofStyle,
+			userId, expectedVersion).Scan(&version)
+		if txerr != nil {
+			panic(txerr)
+		}
+	})
 	if err != nil {
-		log.Printf("Error in isOwner query:%s\n ", err)
-		return false
+		switch err.Error() {
+		case NoResults:
+			err = ErrVersionConflict
+		case ErrAssignmentSubmitted.Error():
+			err = ErrAssignmentSubmitted
+		}
 	}
-	return owner > 0
+	return version, err
 }
 
-func (ss *SurveyStore) IsMember(surveyId uuid.UUID, userId string) bool {
-	var member int
-	err := ss.DS.Select("select count(*) as owner from survey_member where survey_id=$1 and user_id=$2").
+func (ss *SurveyStore) IsOwner(surveyId uuid.UUID, userId string) bool {
+	return ss.GetMemberRole(surveyId, userId) == models.RoleOwner
+}
+
+// GetMemberRole returns a user's role in a survey, or an empty string if they are not a member.  Roles are
+// cached briefly; changes made through the store take effect immediately.
+func (ss *SurveyStore) GetMemberRole(surveyId uuid.UUID, userId string) string {
+	key := memberKey{surveyId, userId}
+	role, ok, generation := cachedMemberRole(key)
+	if ok {
+		return role
+	}
+	err := ss.DS.Select().
+		DataSet(&surveyMemberTable).
+		StatementKey("role").
 		Params(surveyId, userId).
-		Dest(&member).
+		Dest(&role).
 		Fetch()
 	if err != nil {
-		log.Printf("Error in isMember query:%s\n ", err)
-		return false
+		if err.Error() != NoResults {
+			log.Printf("Error in memberRole query:%s\n ", err)
+			return ""
+		}
+		role = ""
 	}
-	return member > 0
+	cacheMemberRole(key, role, generation)
+	return role
+}
+
+func (ss *SurveyStore) IsMember(surveyId uuid.UUID, userId string) bool {
+	return ss.GetMemberRole(surveyId, userId) != ""
 }
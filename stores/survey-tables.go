@@ -16,20 +16,25 @@ var surveyTable = dq.TableDataSet{
 		"insert":     `insert into survey (title,description,active) values ($1,$2,$3) returning id`,
 		"update":     `update survey set title=$1,description=$2,active=$3 where id=$4`,
 		"nsi-survey": fmt.Sprintf(`select $2::uuid as sa_id, false as invalid_structure, false as no_street_view,fd_id,x,y,cbfips,occtype,st_damcat,found_ht,0.0 as num_story, 0.0 as sqft,found_type,
-						'' as rsmeans_type, '' as quality, '' as const_type, '' as garage, '' as roof_style
+						'' as rsmeans_type, '' as quality, '' as const_type, '' as garage, '' as roof_style, false as draft, 0 as version
 						from %s.%s where fd_id=(select fd_id from survey_element where id=$1)`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
-		"survey": `select sa_id, fd_id,x,y,invalid_structure,no_street_view,cbfips,occtype,st_damcat,found_ht,num_story,sqft,
-					found_type,rsmeans_type,quality,const_type,garage,roof_style
-					from survey_result where sa_id=$1`,
-		"user-surveys": `select distinct s.id,s.title,s.description,s.active
+		"survey": `select r.sa_id, r.fd_id,r.x,r.y,r.invalid_structure,r.no_street_view,r.cbfips,r.occtype,r.st_damcat,r.found_ht,r.num_story,r.sqft,
+					r.found_type,r.rsmeans_type,r.quality,r.const_type,r.garage,r.roof_style,not sa.completed as draft,r.version
+					from survey_result r
+					inner join survey_assignment sa on sa.id=r.sa_id
+					where r.sa_id=$1`,
+		"user-surveys": `select distinct s.id,s.title,s.description,s.active,s.archived,s.archived_at
 							from survey s
 							left outer join survey_member sm on sm.survey_id=s.id
-							where sm.user_id=$1`,
-		"admin-surveys": `select distinct s.id,s.title,s.description,s.active
+							where sm.user_id=$1 and not s.archived`,
+		"admin-surveys": `select distinct s.id,s.title,s.description,s.active,s.archived,s.archived_at
 							from survey s
 							left outer join survey_member sm on sm.survey_id=s.id`,
-		"insert-owner": `insert into survey_member (survey_id,user_id,is_owner) values ($1,$2,$3)`,
-		"members": `select distinct m.id, m.user_id, u.user_name, m.is_owner
+		"insert-owner": `insert into survey_member (survey_id,user_id,is_owner,role) values ($1,$2,$3,case when $3 then 'owner' else 'surveyor' end)`,
+		"clone":        `insert into survey (title,description,active) select $2,$3,active from survey where id=$1 returning id`,
+		"archive":      `update survey set archived=$2,archived_at=case when $2 then now() end where id=$1 returning id`,
+		"isArchived":   `select archived from survey where id=$1`,
+		"members": `select distinct m.id, m.user_id, u.user_name, m.is_owner, m.role, coalesce(m.group_name,'') as group_name
                     from survey_member m
                     left outer join users u on m.user_id=u.user_id
                     where m.survey_id=$1`,
@@ -37,28 +42,138 @@ var surveyTable = dq.TableDataSet{
 	Fields: models.Survey{},
 }
 
+// roleRank orders the member role in column %s from most to least privileged
+const roleRank = `array_position(array['owner','coordinator','reviewer','surveyor','viewer']::varchar[],%s)`
+
 var usersTable = dq.TableDataSet{
 	Statements: map[string]string{
-		"insert": `insert into users values ($1,$2)`,
+		"upsert": `insert into users (user_id,user_name,email,last_seen_at) values ($1,$2,nullif($3,''),now())
+					on conflict (user_id) do update set
+					user_name=coalesce(nullif(EXCLUDED.user_name,''),users.user_name),
+					email=coalesce(EXCLUDED.email,users.email),
+					updated_at=case when users.user_name<>coalesce(nullif(EXCLUDED.user_name,''),users.user_name)
+						or users.email is distinct from coalesce(EXCLUDED.email,users.email) then now() else users.updated_at end,
+					last_seen_at=now()`,
+		"known":  `select user_id from users where user_id=any($1)`,
+		"active": `select active from users where user_id=$1`,
+		"select": `select user_id,user_name,coalesce(email,'') as email,active,updated_at,last_seen_at from users
+					where ($1='' or user_id ilike '%'||$1||'%' or user_name ilike '%'||$1||'%' or email ilike '%'||$1||'%')
+					and ($2='' or active=($2='true'))
+					order by user_name,user_id limit $3 offset $4`,
+		"selectById": `select user_id,user_name,coalesce(email,'') as email,active,updated_at,last_seen_at from users where user_id=$1`,
+		"memberships": `select m.survey_id,s.title,m.role,coalesce(m.group_name,'') as group_name,
+					count(sa.id) filter (where not sa.completed) as open,
+					count(sa.id) filter (where sa.completed) as completed
+					from survey_member m
+					inner join survey s on s.id=m.survey_id
+					left outer join survey_element se on se.survey_id=m.survey_id
+					left outer join survey_assignment sa on sa.se_id=se.id and sa.assigned_to=m.user_id
+					where m.user_id=$1
+					group by m.survey_id,s.title,m.role,m.group_name
+					order by s.title`,
+		"setActive": `update users set active=$2,updated_at=now() where user_id=$1 returning user_id`,
 	},
+	Fields: models.UserProfile{},
+}
+
+// mergedDuplicates selects one assignment of each element held by both user $1 and user $2: the one a merge
+// drops, which is user $1's unless only theirs is completed
+const mergedDuplicates = `select case when f.completed and not i.completed then i.id else f.id end
+					from survey_assignment f inner join survey_assignment i on i.se_id=f.se_id and i.assigned_to=$2
+					where f.assigned_to=$1`
+
+// mergedAttachments selects the attachments of the assignments a merge of user $1 into user $2 drops
+const mergedAttachments = `select id,sa_id,file_name,content_type,size,blob_key,thumbnail_key,note,uploaded_by,uploaded_at
+					from survey_attachment where sa_id in (` + mergedDuplicates + `)`
+
+// mergeUserStatements move every record of user $1 to user $2 and then delete user $1.  Where both users
+// hold an element the completed assignment is kept, else user $2's, and where both users are members of a
+// survey the more privileged role is kept.
+var mergeUserStatements = []string{
+	`delete from survey_attachment where sa_id in (` + mergedDuplicates + `)`,
+	`delete from survey_result where sa_id in (` + mergedDuplicates + `)`,
+	`update survey_comment set sa_id=null where sa_id in (` + mergedDuplicates + `)`,
+	`update survey_flag set sa_id=null where sa_id in (` + mergedDuplicates + `)`,
+	`delete from survey_assignment where id in (` + mergedDuplicates + `)`,
+	`update survey_assignment set assigned_to=$2 where assigned_to=$1`,
+	`update survey_result set updated_by=$2 where updated_by=$1`,
+	`update survey_attachment set uploaded_by=$2 where uploaded_by=$1`,
+	`update survey_comment set user_id=$2 where user_id=$1`,
+	`update survey_flag set raised_by=$2 where raised_by=$1`,
+	`update survey_flag set resolved_by=$2 where resolved_by=$1`,
+	`update survey_new_structure set submitted_by=$2 where submitted_by=$1`,
+	`update survey_new_structure set reviewed_by=$2 where reviewed_by=$1`,
+	`update survey_invitation set invited_by=$2 where invited_by=$1`,
+	`update survey_invitation set accepted_by=$2 where accepted_by=$1`,
+	`update service_account set created_by=$2 where created_by=$1`,
+	`update api_key set created_by=$2 where created_by=$1`,
+	`insert into survey_territory_member (territory_id,user_id) select territory_id,$2 from survey_territory_member where user_id=$1
+					on conflict do nothing`,
+	`delete from survey_territory_member where user_id=$1`,
+	`update survey_member t set role=s.role,is_owner=s.is_owner,group_name=s.group_name
+					from survey_member s
+					where s.user_id=$1 and t.user_id=$2 and s.survey_id=t.survey_id
+					and ` + fmt.Sprintf(roleRank, "s.role") + ` < ` + fmt.Sprintf(roleRank, "t.role"),
+	`update survey_member set user_id=$2 where user_id=$1 and survey_id not in (select survey_id from survey_member where user_id=$2)`,
+	`delete from survey_member where user_id=$1`,
+	`delete from idempotency_key where user_id=$1`,
+	`delete from users where user_id=$1`,
 }
 
 var surveyMemberTable = dq.TableDataSet{
 	Statements: map[string]string{
-		"upsert": `insert into survey_member(survey_id,user_id,is_owner) values ($1,$2,$3)
+		"upsert": `insert into survey_member(survey_id,user_id,is_owner,role) values ($1,$2,$3='owner',$3)
 		                   ON CONFLICT(survey_id,user_id) do
-						  update set is_owner=EXCLUDED.is_owner`,
-		"select_owners":    "select * from survey_member where survey_id=$1",
-		"remove":           `delete from survey_member where user_id=$1`,
-		"removeFromSurvey": `delete from survey_member where user_id=$1 and survey_id=$2`,
+						  update set is_owner=EXCLUDED.is_owner,role=EXCLUDED.role,group_name=null`,
+		"role":          `select role from survey_member where survey_id=$1 and user_id=$2`,
+		"select_owners": "select * from survey_member where survey_id=$1",
+		"selectById":    `select id,survey_id,user_id,is_owner,role from survey_member where id=$1`,
+		"remove":        `delete from survey_member where survey_id=$1 and user_id=$2`,
+		"lockOwners":    `select user_id from survey_member where survey_id=$1 and role='owner' for update`,
+		"ownerCount":    `select count(*) from survey_member where survey_id=$1 and role='owner'`,
+		"clone": `insert into survey_member (survey_id,user_id,is_owner,role)
+							select $2,user_id,is_owner,role from survey_member where survey_id=$1 and ($3 or is_owner)`,
 	},
 	Fields: models.SurveyMember{},
 }
 
+// openAssignments selects the incomplete assignments of user $2 in survey $1
+const openAssignments = `select sa.id from survey_assignment sa
+					inner join survey_element se on se.id=sa.se_id
+					where se.survey_id=$1 and sa.assigned_to=$2 and not sa.completed`
+
+var memberRemovalTable = dq.TableDataSet{
+	Name: "survey_assignment",
+	Statements: map[string]string{
+		"openCount": `select count(*) from (` + openAssignments + `) o`,
+		"reassign": `update survey_assignment sa set assigned_to=$3
+					where sa.id in (` + openAssignments + `)
+					and not exists (select 1 from survey_assignment t where t.se_id=sa.se_id and t.assigned_to=$3)`,
+		"attachments": `select id,sa_id,file_name,content_type,size,blob_key,thumbnail_key,note,uploaded_by,uploaded_at
+					from survey_attachment where sa_id in (` + openAssignments + `)`,
+		"releaseAttachments": `delete from survey_attachment where sa_id in (` + openAssignments + `)`,
+		"releaseResults":     `delete from survey_result where sa_id in (` + openAssignments + `)`,
+		"releaseComments":    `update survey_comment set sa_id=null where sa_id in (` + openAssignments + `)`,
+		"releaseFlags":       `update survey_flag set sa_id=null where sa_id in (` + openAssignments + `)`,
+		"release":            `delete from survey_assignment where id in (` + openAssignments + `)`,
+		"territories": `delete from survey_territory_member tm using survey_territory t
+					where t.id=tm.territory_id and t.survey_id=$1 and tm.user_id=$2`,
+	},
+}
+
 var surveyElementTable = dq.TableDataSet{
 	Name: "survey_element",
 	Statements: map[string]string{
 		"select_elements": `select survey_order, fd_id, is_control from survey_element where survey_id=$1`,
+		"selectInSurvey":  `select id, survey_id, survey_order, fd_id, is_control from survey_element where id=$1 and survey_id=$2`,
+		"clone": `insert into survey_element (survey_id,survey_order,fd_id,is_control)
+							select $2,
+							case when $3 then row_number() over (order by random()) else survey_order end,
+							fd_id,
+							case when $4 then is_control else false end
+							from survey_element where survey_id=$1`,
+		"randomControls": `update survey_element set is_control=true
+							where id in (select id from survey_element where survey_id=$1 order by random() limit $2)`,
 	},
 	Fields: models.SurveyElement{},
 }
@@ -67,82 +182,25 @@ var surveyAssignmentTable = dq.TableDataSet{
 	Name: "survey_assignment",
 	Statements: map[string]string{
 		"updateAssignment": `update survey_assignment set completed='true' where id=$1`,
-		"assignSurvey":     `insert into survey_assignment (se_id,assigned_to) values ($1,$2) returning id`,
-		"assignmentInfo": `
-			select
-				sa_id,
-				se_id,
-				completed,
-				survey_order,
-				next_survey_order,
-				next_survey_seid,
-				next_control_order,
-				next_control_seid
-			from (
-				select
-					t1.id as sa_id,
-					t1.se_id,
-					t1.completed,
-					t2.survey_order,
-					null as next_survey_order,
-					null as next_survey_seid,
-					null as next_control_order,
-					null as next_control_seid
-				from survey_element t2
-				left outer join survey_assignment t1 on t1.se_id=t2.id
-				where assigned_to=$1 and t2.survey_id=$2 and completed='false'
-				union
-				select
-					sa_id,
-					se_id,
-					completed,
-					next_assignment.survey_order,
-					next_survey_order,
-					se1.id as next_survey_seid,
-					next_control_order,
-					se2.id as next_control_seid
-				from (
-					select
-						null::uuid as sa_id,
-						null::uuid as se_id,
-						null::bool as completed,
-						null::integer as survey_order,
-						(
-							select case when (
-								select max(t2.survey_order)
-								from survey_assignment t1
-								inner join survey_element t2 on t2.id=t1.se_id
-								where t2.survey_id=$2 and t2.is_control='false'
-							) is null then (
-								select min(survey_order)
-								from survey_element
-								where survey_id=$2 and is_control='false'
-							) else (
-								select min(survey_order)
-								from survey_element where survey_order> (
-									select max(t2.survey_order)
-										from survey_assignment t1
-										inner join survey_element t2 on t2.id=t1.se_id
-										where t2.survey_id=$2 and t2.is_control='false'
-								) and is_control='false' and survey_id=$2
-							) end
-						) as next_survey_order,
-						(
-							select min(t1.survey_order)
-							from survey_element t1
-							left outer join (
-								select *
-								from survey_assignment
-								where assigned_to=$1
-							) t2 on t1.id=t2.se_id
-							where assigned_to is null and is_control='true' and survey_id=$2
-						) as next_control_order
-								) next_assignment
-								inner join survey_element se1 on se1.survey_order=next_assignment.next_survey_order
-								left outer join survey_element se2 on se2.survey_order=next_assignment.next_control_order
-								where se1.survey_id=$2 and (se2.survey_id=$2  or se2.survey_id is null)
-							) assignment_query
-							order by survey_order limit 1`,
+		"lockAssignment":   `select completed from survey_assignment where id=$1 for update`,
+		"reopenAssignment": `update survey_assignment set completed='false' where id=$1 and completed='true' returning id`,
+		"memberAssignments": `select sa.id as sa_id, sa.se_id, se.fd_id, se.survey_order, se.is_control, sa.completed,
+							(r.id is not null and not sa.completed) as draft
+							from survey_assignment sa
+							inner join survey_element se on se.id=sa.se_id
+							left outer join survey_result r on r.sa_id=sa.id
+							where sa.assigned_to=$1 and se.survey_id=$2 and (
+								$3='' or
+								($3='completed' and sa.completed) or
+								($3='open' and not sa.completed) or
+								($3='draft' and not sa.completed and r.id is not null)
+							)
+							order by se.survey_order
+							limit $4 offset $5`,
+		"selectInSurvey": `select sa.id,sa.se_id,sa.completed,coalesce(sa.assigned_to,'') as assigned_to
+							from survey_assignment sa
+							inner join survey_element se on se.id=sa.se_id
+							where sa.id=$1 and se.survey_id=$2`,
 	},
 	Fields: models.SurveyAssignment{},
 }
@@ -155,13 +213,29 @@ var resultTable = dq.TableDataSet{
 						from %s.%s where fd_id=(select fd_id from survey_element where id=$1)`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
 
 		"upsertSurveyStructure": `insert into survey_result
-									(sa_id,fd_id,x,y,invalid_structure,no_street_view,cbfips,occtype,st_damcat,found_ht,num_story,sqft,found_type,rsmeans_type,quality,const_type,garage,roof_style)
-									values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18)
+									(sa_id,fd_id,x,y,invalid_structure,no_street_view,cbfips,occtype,st_damcat,found_ht,num_story,sqft,found_type,rsmeans_type,quality,const_type,garage,roof_style,updated_by)
+									values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19)
+									ON CONFLICT (sa_id)
+									DO UPDATE SET x=EXCLUDED.x,y=EXCLUDED.y,invalid_structure=EXCLUDED.invalid_structure,no_street_view=EXCLUDED.no_street_view, cbfips=EXCLUDED.cbfips,
+													occtype=EXCLUDED.occtype,st_damcat=EXCLUDED.st_damcat,found_ht=EXCLUDED.found_ht,num_story=EXCLUDED.num_story,
+												sqft=EXCLUDED.sqft,found_type=EXCLUDED.found_type,rsmeans_type=EXCLUDED.rsmeans_type,
+												quality=EXCLUDED.quality,const_type=EXCLUDED.const_type,garage=EXCLUDED.garage,roof_style=EXCLUDED.roof_style,
+												version=survey_result.version+1,updated_by=EXCLUDED.updated_by,updated_at=now()
+									WHERE $20::int is null or survey_result.version=$20
+									returning version`,
+		"upsertDraft": `insert into survey_result
+									(sa_id,fd_id,x,y,invalid_structure,no_street_view,cbfips,occtype,st_damcat,found_ht,num_story,sqft,found_type,rsmeans_type,quality,const_type,garage,roof_style,updated_by)
+									select $1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19
+									from survey_assignment where id=$1 and not completed
 									ON CONFLICT (sa_id)
 									DO UPDATE SET x=EXCLUDED.x,y=EXCLUDED.y,invalid_structure=EXCLUDED.invalid_structure,no_street_view=EXCLUDED.no_street_view, cbfips=EXCLUDED.cbfips,
 													occtype=EXCLUDED.occtype,st_damcat=EXCLUDED.st_damcat,found_ht=EXCLUDED.found_ht,num_story=EXCLUDED.num_story,
 												sqft=EXCLUDED.sqft,found_type=EXCLUDED.found_type,rsmeans_type=EXCLUDED.rsmeans_type,
-												quality=EXCLUDED.quality,const_type=EXCLUDED.const_type,garage=EXCLUDED.garage,roof_style=EXCLUDED.roof_style`,
+												quality=EXCLUDED.quality,const_type=EXCLUDED.const_type,garage=EXCLUDED.garage,roof_style=EXCLUDED.roof_style,
+												version=survey_result.version+1,updated_by=EXCLUDED.updated_by,updated_at=now()
+									WHERE ($20::int is null or survey_result.version=$20)
+									and not exists (select 1 from survey_assignment sa where sa.id=survey_result.sa_id and sa.completed)
+									returning version`,
 
 		"surveyReport": `select
 				t1.id as sr_id,
@@ -186,7 +260,8 @@ var resultTable = dq.TableDataSet{
 				t1.garage,
 				t1.roof_style,
 				t1.invalid_structure,
-				t1.no_street_view
+				t1.no_street_view,
+				not t2.completed as draft
 				from survey_result t1
 				inner join survey_assignment t2 on t2.id=t1.sa_id
 				inner join users t3 on t3.user_id=t2.assigned_to
@@ -194,3 +269,440 @@ var resultTable = dq.TableDataSet{
 				where t4.survey_id=$1`,
 	},
 }
+
+var surveyDomainTable = dq.TableDataSet{
+	Name: "survey_domain",
+	Statements: map[string]string{
+		"select": `select survey_id,column_name,code,description from survey_domain where survey_id=$1 order by column_name,ordinal`,
+		"delete": `delete from survey_domain where survey_id=$1 and column_name=$2`,
+		"insert": `insert into survey_domain (survey_id,column_name,code,description,ordinal) values ($1,$2,$3,$4,$5)`,
+		"clone":  `insert into survey_domain (survey_id,column_name,code,description,ordinal) select $2,column_name,code,description,ordinal from survey_domain where survey_id=$1`,
+	},
+	Fields: models.SurveyDomainValue{},
+}
+
+var attachmentTable = dq.TableDataSet{
+	Name: "survey_attachment",
+	Statements: map[string]string{
+		"insert": `insert into survey_attachment (id,sa_id,file_name,content_type,size,blob_key,thumbnail_key,note,uploaded_by)
+					values ($1,$2,$3,$4,$5,$6,$7,$8,$9)`,
+		"selectById": `select a.id,a.sa_id,a.file_name,a.content_type,a.size,a.blob_key,a.thumbnail_key,a.note,a.uploaded_by,a.uploaded_at
+					from survey_attachment a
+					inner join survey_assignment sa on sa.id=a.sa_id
+					inner join survey_element se on se.id=sa.se_id
+					where a.id=$1 and se.survey_id=$2`,
+		"selectByAssignment": `select id,sa_id,file_name,content_type,size,blob_key,thumbnail_key,note,uploaded_by,uploaded_at
+					from survey_attachment where sa_id=$1 order by uploaded_at`,
+		"selectBySurvey": `select a.id,a.sa_id,a.file_name,a.content_type,a.size,a.blob_key,a.thumbnail_key,a.note,a.uploaded_by,a.uploaded_at
+					from survey_attachment a
+					inner join survey_assignment sa on sa.id=a.sa_id
+					inner join survey_element se on se.id=sa.se_id
+					where se.survey_id=$1 order by a.uploaded_at`,
+		"delete": `delete from survey_attachment where id=$1`,
+	},
+	Fields: models.Attachment{},
+}
+
+var commentTable = dq.TableDataSet{
+	Name: "survey_comment",
+	Statements: map[string]string{
+		"insert": `insert into survey_comment (survey_id,se_id,sa_id,parent_id,user_id,body) values ($1,$2,$3,$4,$5,$6) returning id`,
+		"selectById": `select c.id,c.survey_id,c.se_id,c.sa_id,c.parent_id,c.user_id,coalesce(u.user_name,'') as user_name,c.body,c.created_at
+					from survey_comment c
+					left outer join users u on u.user_id=c.user_id
+					where c.id=$1`,
+		"selectByElement": `select c.id,c.survey_id,c.se_id,c.sa_id,c.parent_id,c.user_id,coalesce(u.user_name,'') as user_name,c.body,c.created_at
+					from survey_comment c
+					left outer join users u on u.user_id=c.user_id
+					where c.se_id=$1
+					order by c.created_at`,
+	},
+	Fields: models.Comment{},
+}
+
+var flagTable = dq.TableDataSet{
+	Name: "survey_flag",
+	Statements: map[string]string{
+		"insert": `insert into survey_flag (survey_id,se_id,sa_id,flag_type,note,raised_by) values ($1,$2,$3,$4,$5,$6) returning id`,
+		"select": `select f.id,f.survey_id,f.se_id,f.sa_id,se.fd_id,f.flag_type,f.note,f.raised_by,coalesce(u.user_name,'') as raised_by_name,
+						f.raised_at,f.resolved,f.resolved_by,f.resolved_at,f.resolution
+					from survey_flag f
+					inner join survey_element se on se.id=f.se_id
+					left outer join users u on u.user_id=f.raised_by
+					where f.survey_id=$1 and ($2::boolean is null or f.resolved=$2) and ($3='' or f.flag_type=$3)
+					order by f.raised_at`,
+		"selectByElement": `select f.id,f.survey_id,f.se_id,f.sa_id,se.fd_id,f.flag_type,f.note,f.raised_by,coalesce(u.user_name,'') as raised_by_name,
+						f.raised_at,f.resolved,f.resolved_by,f.resolved_at,f.resolution
+					from survey_flag f
+					inner join survey_element se on se.id=f.se_id
+					left outer join users u on u.user_id=f.raised_by
+					where f.se_id=$1
+					order by f.raised_at`,
+		"resolve": `update survey_flag set resolved=true,resolved_by=$1,resolved_at=now(),resolution=$2
+					where id=$3 and survey_id=$4 and resolved=false returning id`,
+	},
+	Fields: models.Flag{},
+}
+
+var newStructureTable = dq.TableDataSet{
+	Name: "survey_new_structure",
+	Statements: map[string]string{
+		"insert": `insert into survey_new_structure
+					(survey_id,submitted_by,x,y,cbfips,occtype,st_damcat,found_ht,num_story,sqft,found_type,rsmeans_type,quality,const_type,garage,roof_style)
+					values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+					returning id`,
+		"select": `select ns.id,ns.survey_id,ns.provisional_id,ns.submitted_by,coalesce(u.user_name,'') as submitted_by_name,ns.submitted_at,
+						ns.status,ns.reviewed_by,ns.reviewed_at,ns.review_note,ns.x,ns.y,ns.cbfips,ns.occtype,ns.st_damcat,ns.found_ht,
+						ns.num_story,ns.sqft,ns.found_type,ns.rsmeans_type,ns.quality,ns.const_type,ns.garage,ns.roof_style
+					from survey_new_structure ns
+					left outer join users u on u.user_id=ns.submitted_by
+					where ns.survey_id=$1 and ($2='' or ns.status=$2)
+					order by ns.provisional_id`,
+		"selectById": `select ns.id,ns.survey_id,ns.provisional_id,ns.submitted_by,coalesce(u.user_name,'') as submitted_by_name,ns.submitted_at,
+						ns.status,ns.reviewed_by,ns.reviewed_at,ns.review_note,ns.x,ns.y,ns.cbfips,ns.occtype,ns.st_damcat,ns.found_ht,
+						ns.num_story,ns.sqft,ns.found_type,ns.rsmeans_type,ns.quality,ns.const_type,ns.garage,ns.roof_style
+					from survey_new_structure ns
+					left outer join users u on u.user_id=ns.submitted_by
+					where ns.id=$1 and ns.survey_id=$2`,
+		"review": `update survey_new_structure set status=$1,reviewed_by=$2,reviewed_at=now(),review_note=$3
+					where id=$4 and survey_id=$5 returning id`,
+	},
+	Fields: models.NewStructure{},
+}
+
+var idempotencyTable = dq.TableDataSet{
+	Name: "idempotency_key",
+	Statements: map[string]string{
+		"expire":   `delete from idempotency_key where created_at < now() - interval '24 hours'`,
+		"reserve":  `insert into idempotency_key (user_id,idempotency_key,request_hash) values ($1,$2,$3) on conflict do nothing returning idempotency_key`,
+		"select":   `select request_hash,status_code,response,content_type,etag from idempotency_key where user_id=$1 and idempotency_key=$2`,
+		"complete": `update idempotency_key set status_code=$1,response=$2,content_type=$3,etag=$4 where user_id=$5 and idempotency_key=$6`,
+		"release":  `delete from idempotency_key where user_id=$1 and idempotency_key=$2`,
+	},
+	Fields: models.IdempotentResponse{},
+}
+
+// unassignedRegular restricts survey_element se to the regular elements of survey $2 that have not been assigned
+const unassignedRegular = `se.survey_id=$2 and se.is_control='false' and not exists (select 1 from survey_assignment sa where sa.se_id=se.id)`
+
+// inTerritory restricts survey_element se to the territories user $1 is bound to in survey $2.  Users that
+// are not bound to a territory may be issued any element.
+var inTerritory = fmt.Sprintf(`(not exists (select 1 from survey_territory_member tm
+						inner join survey_territory t on t.id=tm.territory_id
+						where t.survey_id=$2 and tm.user_id=$1)
+					or exists (select 1 from survey_territory_member tm
+						inner join survey_territory t on t.id=tm.territory_id
+						inner join %s.%s tn on tn.fd_id=se.fd_id
+						where t.survey_id=$2 and tm.user_id=$1
+						and (point(tn.x,tn.y) <@ t.boundary or left(tn.cbfips,12)=any(t.block_groups))))`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME)
+
+// withinTerritory matches NSI structure n to territory t
+const withinTerritory = `(point(n.x,n.y) <@ t.boundary or left(n.cbfips,12)=any(t.block_groups))`
+
+// lastCompleted selects the most recent result completed by user $1 in survey $2
+const lastCompleted = `select r.x, r.y, left(r.cbfips,12) as block_group
+					from survey_result r
+					inner join survey_assignment sa on sa.id=r.sa_id
+					inner join survey_element e on e.id=sa.se_id
+					where sa.assigned_to=$1 and e.survey_id=$2 and sa.completed='true'
+					order by r.updated_at desc limit 1`
+
+var assignmentEngineTable = dq.TableDataSet{
+	Name: "survey_element",
+	Statements: map[string]string{
+		"nextControl": `select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where se.survey_id=$2 and se.is_control='true'
+					and not exists (select 1 from survey_assignment sa where sa.se_id=se.id and sa.assigned_to=$1)
+					and se.survey_order < coalesce((select min(se.survey_order) from survey_element se where ` + unassignedRegular + `), 2147483647)
+					order by se.survey_order limit 1`,
+		"nextControlByRate": `select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where se.survey_id=$2 and se.is_control='true'
+					and not exists (select 1 from survey_assignment sa where sa.se_id=se.id and sa.assigned_to=$1)
+					order by se.survey_order limit 1`,
+		"nextControlByProgress": `select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where se.survey_id=$2 and se.is_control='true'
+					and not exists (select 1 from survey_assignment sa where sa.se_id=se.id and sa.assigned_to=$1)
+					and (select count(*) from survey_element e where e.survey_id=$2 and e.is_control='false' and e.survey_order < se.survey_order) <=
+						(select count(*) from survey_assignment sa inner join survey_element e on e.id=sa.se_id
+						where sa.assigned_to=$1 and e.survey_id=$2 and e.is_control='false')
+					order by se.survey_order limit 1`,
+		"assignElement": `insert into survey_assignment (se_id,assigned_to)
+					select se.id,$2 from survey_element se
+					where se.id=$1 and not exists (select 1 from survey_assignment sa where sa.se_id=se.id and (se.is_control='false' or sa.assigned_to=$2))
+					on conflict do nothing
+					returning id`,
+		"lockMember": `select id from survey_member where survey_id=$1 and user_id=$2 for update`,
+		"memberCounts": `select count(*) as total,
+					count(*) filter (where sa.assigned_at >= date_trunc('day',now())) as today,
+					count(*) filter (where not e.is_control) as regular,
+					count(*) filter (where e.is_control) as controls
+					from survey_assignment sa
+					inner join survey_element e on e.id=sa.se_id
+					where sa.assigned_to=$1 and e.survey_id=$2`,
+		"next-survey-order": `select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where ` + unassignedRegular + ` and ` + inTerritory + `
+					order by se.survey_order limit 1
+					for update of se skip locked`,
+		"next-random": `select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					where ` + unassignedRegular + ` and ` + inTerritory + `
+					order by random() limit 1
+					for update of se skip locked`,
+		"next-nearest": fmt.Sprintf(`select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					left outer join %s.%s n on n.fd_id=se.fd_id
+					left outer join (`+lastCompleted+`) lc on true
+					where `+unassignedRegular+` and `+inTerritory+`
+					order by (n.x-lc.x)^2 + (n.y-lc.y)^2 nulls last, se.survey_order limit 1
+					for update of se skip locked`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
+		"next-block-cluster": fmt.Sprintf(`select se.id, se.survey_id, se.survey_order, se.fd_id, se.is_control
+					from survey_element se
+					left outer join %s.%s n on n.fd_id=se.fd_id
+					left outer join (`+lastCompleted+`) lc on true
+					where `+unassignedRegular+` and `+inTerritory+`
+					order by coalesce(left(n.cbfips,12)=lc.block_group, false) desc, se.survey_order limit 1
+					for update of se skip locked`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
+	},
+	Fields: models.SurveyElement{},
+}
+
+var surveySettingTable = dq.TableDataSet{
+	Name: "survey_setting",
+	Statements: map[string]string{
+		"select": `select survey_id,assignment_strategy,max_assignments,daily_cap,control_rate from survey_setting where survey_id=$1`,
+		"upsert": `insert into survey_setting (survey_id,assignment_strategy,max_assignments,daily_cap,control_rate) values ($1,$2,$3,$4,$5)
+					on conflict (survey_id) do update set assignment_strategy=EXCLUDED.assignment_strategy,max_assignments=EXCLUDED.max_assignments,
+					daily_cap=EXCLUDED.daily_cap,control_rate=EXCLUDED.control_rate`,
+		"clone": `insert into survey_setting (survey_id,assignment_strategy,max_assignments,daily_cap,control_rate)
+					select $2,assignment_strategy,max_assignments,daily_cap,control_rate from survey_setting where survey_id=$1`,
+	},
+	Fields: models.SurveySettings{},
+}
+
+var territoryTable = dq.TableDataSet{
+	Name: "survey_territory",
+	Statements: map[string]string{
+		"select": `select id,survey_id,name,coalesce(boundary::text,'') as boundary,array_to_string(block_groups,',') as block_groups
+					from survey_territory where survey_id=$1 order by name`,
+		"selectById": `select id,survey_id,name,coalesce(boundary::text,'') as boundary,array_to_string(block_groups,',') as block_groups
+					from survey_territory where id=$1 and survey_id=$2`,
+		"insert": `insert into survey_territory (survey_id,name,boundary,block_groups)
+					values ($1,$2,nullif($3,'')::polygon,string_to_array($4,',')) returning id`,
+		"update": `update survey_territory set name=$3,boundary=nullif($4,'')::polygon,block_groups=string_to_array($5,',')
+					where id=$1 and survey_id=$2 returning id`,
+		"delete": `delete from survey_territory where id=$1 and survey_id=$2 returning id`,
+		"members": `select tm.territory_id,tm.user_id,u.user_name
+					from survey_territory_member tm
+					inner join survey_territory t on t.id=tm.territory_id
+					inner join users u on u.user_id=tm.user_id
+					where t.survey_id=$1
+					order by u.user_name`,
+		"deleteMembers": `delete from survey_territory_member where territory_id=$1`,
+		"insertMember":  `insert into survey_territory_member (territory_id,user_id) values ($1,$2) on conflict do nothing`,
+		"clone": `insert into survey_territory (survey_id,name,boundary,block_groups)
+					select $2,name,boundary,block_groups from survey_territory where survey_id=$1`,
+		"cloneMembers": `insert into survey_territory_member (territory_id,user_id)
+					select nt.id,tm.user_id
+					from survey_territory_member tm
+					inner join survey_territory ot on ot.id=tm.territory_id
+					inner join survey_territory nt on nt.survey_id=$2 and nt.name=ot.name
+					inner join survey_member sm on sm.survey_id=$2 and sm.user_id=tm.user_id
+					where ot.survey_id=$1`,
+		"coverage": fmt.Sprintf(`select t.id as territory_id,t.name,
+					(select count(*) from survey_territory_member tm where tm.territory_id=t.id) as members,
+					count(se.id) as elements,count(sa.id) as assigned,count(sa.id) filter (where sa.completed) as completed
+					from survey_territory t
+					left outer join lateral (
+						select e.id from survey_element e
+						inner join %s.%s n on n.fd_id=e.fd_id
+						where e.survey_id=t.survey_id and e.is_control='false' and `+withinTerritory+`
+					) se on true
+					left outer join survey_assignment sa on sa.se_id=se.id
+					where t.survey_id=$1
+					group by t.id,t.name
+					order by t.name`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
+		"outsideCoverage": fmt.Sprintf(`select '00000000-0000-0000-0000-000000000000'::uuid as territory_id,'' as name,0 as members,
+					count(e.id) as elements,count(sa.id) as assigned,count(sa.id) filter (where sa.completed) as completed
+					from survey_element e
+					left outer join %s.%s n on n.fd_id=e.fd_id
+					left outer join survey_assignment sa on sa.se_id=e.id
+					where e.survey_id=$1 and e.is_control='false'
+					and not exists (select 1 from survey_territory t where t.survey_id=e.survey_id and `+withinTerritory+`)`, global.DB_NSI_SCHEMA, global.DB_NSI_TABLENAME),
+	},
+	Fields: models.Territory{},
+}
+
+var surveyGroupTable = dq.TableDataSet{
+	Name: "survey_group",
+	Statements: map[string]string{
+		"select": `select g.survey_id,g.group_name,g.role,
+					(select count(*) from survey_member m where m.survey_id=g.survey_id and m.group_name=g.group_name) as members
+					from survey_group g where g.survey_id=$1 order by g.group_name`,
+		"upsert": `insert into survey_group (survey_id,group_name,role) values ($1,$2,$3)
+					on conflict (survey_id,group_name) do update set role=EXCLUDED.role`,
+		"updateMembers": `update survey_member set role=$3,is_owner=$3='owner' where survey_id=$1 and group_name=$2`,
+		"delete":        `delete from survey_group where survey_id=$1 and group_name=$2 returning group_name`,
+		"members":       `select user_id from survey_member where survey_id=$1 and group_name=$2`,
+		"syncStale": `select m.survey_id from survey_member m where m.user_id=$1 and m.group_name is not null
+					and not exists (select 1 from survey_group g where g.survey_id=m.survey_id and g.group_name=any($2))`,
+		"syncAdd": `insert into survey_member (survey_id,user_id,is_owner,role,group_name)
+					select distinct on (g.survey_id) g.survey_id,$1,g.role='owner',g.role,g.group_name
+					from survey_group g where g.group_name=any($2)
+					order by g.survey_id,` + fmt.Sprintf(roleRank, "g.role") + `
+					on conflict (survey_id,user_id) do update
+					set is_owner=EXCLUDED.is_owner,role=EXCLUDED.role,group_name=EXCLUDED.group_name
+					where survey_member.group_name is not null`,
+	},
+	Fields: models.SurveyGroup{},
+}
+
+// invitationColumns selects an invitation, reporting pending invitations past their expiry as expired
+const invitationColumns = `id,survey_id,coalesce(email,'') as email,coalesce(subject,'') as subject,role,
+					case when status='pending' and expires_at<=now() then 'expired' else status end as status,
+					invited_by,created_at,expires_at,coalesce(accepted_by,'') as accepted_by,accepted_at`
+
+var invitationTable = dq.TableDataSet{
+	Name: "survey_invitation",
+	Statements: map[string]string{
+		"insert": `insert into survey_invitation (survey_id,email,subject,role,invited_by,expires_at)
+					values ($1,nullif($2,''),nullif($3,''),$4,$5,now()+make_interval(days => $6))
+					returning id`,
+		"select": `select ` + invitationColumns + ` from survey_invitation
+					where survey_id=$1 and ($2='' or $2=(case when status='pending' and expires_at<=now() then 'expired' else status end))
+					order by created_at desc`,
+		"selectById": `select ` + invitationColumns + ` from survey_invitation where survey_id=$1 and id=$2`,
+		"revoke": `update survey_invitation set status='revoked'
+					where survey_id=$1 and id=$2 and status='pending' and expires_at>now()
+					returning id`,
+		"accept": `with accepted as (
+						update survey_invitation set status='accepted',accepted_by=$1,accepted_at=now()
+						where status='pending' and expires_at>now()
+						and (subject=$1 or ($2<>'' and lower(email)=lower($2) and role not in ('owner','coordinator')))
+						returning survey_id,role
+					)
+					insert into survey_member (survey_id,user_id,is_owner,role)
+					select distinct on (survey_id) survey_id,$1,role='owner',role from accepted
+					order by survey_id
+					on conflict (survey_id,user_id) do nothing`,
+	},
+	Fields: models.Invitation{},
+}
+
+// serviceAccountColumns selects a service account along with the number of keys that are neither revoked nor expired
+const serviceAccountColumns = `a.id,a.name,a.description,a.active,a.created_by,a.created_at,
+					(select count(*) from api_key k where k.account_id=a.id and k.revoked_at is null
+					and (k.expires_at is null or k.expires_at>now())) as active_keys`
+
+var serviceAccountTable = dq.TableDataSet{
+	Name: "service_account",
+	Statements: map[string]string{
+		"insert":     `insert into service_account (name,description,created_by) values ($1,$2,$3) on conflict (name) do nothing returning id`,
+		"select":     `select ` + serviceAccountColumns + ` from service_account a order by a.name`,
+		"selectById": `select ` + serviceAccountColumns + ` from service_account a where a.id=$1`,
+		"setActive":  `update service_account set active=$2 where id=$1 returning id`,
+	},
+	Fields: models.ServiceAccount{},
+}
+
+// apiKeyColumns selects an API key with its permissions and surveys as comma separated lists
+const apiKeyColumns = `k.id,k.account_id,k.name,k.prefix,array_to_string(k.permissions,',') as permissions,
+					coalesce((select string_agg(s.survey_id::text,',' order by s.survey_id) from api_key_survey s where s.key_id=k.id),'') as surveys,
+					k.created_by,k.created_at,k.expires_at,k.last_used_at,k.revoked_at`
+
+var apiKeyTable = dq.TableDataSet{
+	Name: "api_key",
+	Statements: map[string]string{
+		"insert": `with k as (
+						insert into api_key (account_id,name,prefix,key_hash,permissions,created_by,expires_at)
+						values ($1,$2,$3,$4,string_to_array($5,','),$6,case when $7::int>0 then now()+make_interval(days => $7::int) end)
+						returning id
+					), s as (
+						insert into api_key_survey (key_id,survey_id)
+						select k.id,unnest(string_to_array($8,',')::uuid[]) from k
+					)
+					select id from k`,
+		"select":     `select ` + apiKeyColumns + ` from api_key k where k.account_id=$1 order by k.created_at desc`,
+		"selectById": `select ` + apiKeyColumns + ` from api_key k where k.account_id=$1 and k.id=$2`,
+		"revoke":     `update api_key set revoked_at=now() where account_id=$1 and id=$2 and revoked_at is null returning id`,
+		"authenticate": `select ` + apiKeyColumns + ` from api_key k
+					inner join service_account a on a.id=k.account_id
+					where k.prefix=$1 and k.key_hash=$2 and k.revoked_at is null
+					and (k.expires_at is null or k.expires_at>now()) and a.active`,
+		"touch": `update api_key set last_used_at=now()
+					where id=$1 and (last_used_at is null or last_used_at<now()-interval '1 minute')`,
+	},
+	Fields: models.APIKey{},
+}
+
+// purgeStatements delete every row belonging to survey $1, in foreign key order
+var purgeStatements = []string{
+	`delete from survey_attachment where sa_id in (select sa.id from survey_assignment sa inner join survey_element se on se.id=sa.se_id where se.survey_id=$1)`,
+	`delete from survey_comment where survey_id=$1`,
+	`delete from survey_flag where survey_id=$1`,
+	`delete from survey_result where sa_id in (select sa.id from survey_assignment sa inner join survey_element se on se.id=sa.se_id where se.survey_id=$1)`,
+	`delete from survey_assignment where se_id in (select id from survey_element where survey_id=$1)`,
+	`delete from survey_element where survey_id=$1`,
+	`delete from survey_new_structure where survey_id=$1`,
+	`delete from survey_territory where survey_id=$1`,
+	`delete from survey_setting where survey_id=$1`,
+	`delete from survey_domain where survey_id=$1`,
+	`delete from survey_invitation where survey_id=$1`,
+	`delete from survey_group where survey_id=$1`,
+	`delete from survey_member where survey_id=$1`,
+	`delete from api_key_survey where survey_id=$1`,
+	`delete from survey where id=$1`,
+}
+
+var surveyBackupTable = dq.TableDataSet{
+	Name: "survey",
+	Statements: map[string]string{
+		"users": `select distinct u.user_id,u.user_name from users u
+					where u.user_id in (
+						select user_id from survey_member where survey_id=$1
+						union select sa.assigned_to from survey_assignment sa inner join survey_element se on se.id=sa.se_id where se.survey_id=$1
+						union select r.updated_by from survey_result r inner join survey_assignment sa on sa.id=r.sa_id inner join survey_element se on se.id=sa.se_id where se.survey_id=$1
+						union select user_id from survey_comment where survey_id=$1
+						union select raised_by from survey_flag where survey_id=$1
+						union select resolved_by from survey_flag where survey_id=$1
+					)
+					order by u.user_id`,
+		"members":  `select id,survey_id,user_id,is_owner,role from survey_member where survey_id=$1 order by user_id`,
+		"elements": `select id,survey_id,survey_order,fd_id,is_control from survey_element where survey_id=$1 order by survey_order`,
+		"assignments": `select sa.id,sa.se_id,sa.completed,coalesce(sa.assigned_to,'') as assigned_to,sa.assigned_at
+					from survey_assignment sa
+					inner join survey_element se on se.id=sa.se_id
+					where se.survey_id=$1
+					order by sa.assigned_at`,
+		"results": `select r.id,r.sa_id,r.fd_id,r.x,r.y,r.invalid_structure,r.no_street_view,r.cbfips,r.occtype,r.st_damcat,r.found_ht,
+					r.num_story,r.sqft,r.found_type,r.rsmeans_type,r.quality,r.const_type,r.garage,r.roof_style,r.version,r.updated_by,r.updated_at
+					from survey_result r
+					inner join survey_assignment sa on sa.id=r.sa_id
+					inner join survey_element se on se.id=sa.se_id
+					where se.survey_id=$1
+					order by r.updated_at`,
+		"comments": `select c.id,c.survey_id,c.se_id,c.sa_id,c.parent_id,c.user_id,coalesce(u.user_name,'') as user_name,c.body,c.created_at
+					from survey_comment c
+					left outer join users u on u.user_id=c.user_id
+					where c.survey_id=$1
+					order by c.created_at`,
+		"insertSurvey":     `insert into survey (id,title,description,active) values ($1,$2,$3,$4)`,
+		"insertUser":       `insert into users (user_id,user_name) values ($1,$2) on conflict do nothing`,
+		"insertMember":     `insert into survey_member (id,survey_id,user_id,is_owner,role) values ($1,$2,$3,$4='owner',$4)`,
+		"insertElement":    `insert into survey_element (id,survey_id,survey_order,fd_id,is_control) values ($1,$2,$3,$4,$5)`,
+		"insertAssignment": `insert into survey_assignment (id,se_id,completed,assigned_to,assigned_at) values ($1,$2,$3,nullif($4,''),$5)`,
+		"insertResult": `insert into survey_result
+					(id,sa_id,fd_id,x,y,invalid_structure,no_street_view,cbfips,occtype,st_damcat,found_ht,num_story,sqft,found_type,
+					rsmeans_type,quality,const_type,garage,roof_style,version,updated_by,updated_at)
+					values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22)`,
+		"insertTerritory": `insert into survey_territory (id,survey_id,name,boundary,block_groups)
+					values ($1,$2,$3,nullif($4,'')::polygon,string_to_array($5,','))`,
+		"insertComment": `insert into survey_comment (id,survey_id,se_id,sa_id,parent_id,user_id,body,created_at) values ($1,$2,$3,$4,$5,$6,$7,$8)`,
+		"insertFlag": `insert into survey_flag (id,survey_id,se_id,sa_id,flag_type,note,raised_by,raised_at,resolved,resolved_by,resolved_at,resolution)
+					values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
+	},
+	Fields: models.SurveyArchive{},
+}
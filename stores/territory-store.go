@@ -0,0 +1,145 @@
+package stores
+
+import (
+	"context"
+	"strings"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/google/uuid"
+	"github.com/usace/goquery"
+)
+
+// GetTerritories lists the territories of a survey along with their members
+func (ss *SurveyStore) GetTerritories(surveyId uuid.UUID) ([]models.Territory, error) {
+	territories := []models.Territory{}
+	err := ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("select").
+		Params(surveyId).
+		Dest(&territories).
+		Fetch()
+	if err != nil {
+		return nil, err
+	}
+	members := []models.TerritoryMember{}
+	err = ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("members").
+		Params(surveyId).
+		Dest(&members).
+		Fetch()
+	if err != nil {
+		return nil, err
+	}
+	byTerritory := map[uuid.UUID][]string{}
+	for _, m := range members {
+		byTerritory[m.TerritoryID] = append(byTerritory[m.TerritoryID], m.UserID)
+	}
+	for i := range territories {
+		if err := expandTerritory(&territories[i]); err != nil {
+			return nil, err
+		}
+		territories[i].Members = byTerritory[territories[i].ID]
+	}
+	return territories, nil
+}
+
+func (ss *SurveyStore) GetTerritory(surveyId uuid.UUID, territoryId uuid.UUID) (models.Territory, error) {
+	territory := models.Territory{}
+	err := ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("selectById").
+		Params(territoryId, surveyId).
+		Dest(&territory).
+		Fetch()
+	if err != nil {
+		return territory, err
+	}
+	return territory, expandTerritory(&territory)
+}
+
+// expandTerritory fills the polygon and block groups of a territory from their stored text forms
+func expandTerritory(t *models.Territory) error {
+	polygon, err := models.ParsePolygon(t.Boundary)
+	if err != nil {
+		return err
+	}
+	t.Polygon = polygon
+	t.BlockGroups = []string{}
+	if t.BlockGroupList != "" {
+		t.BlockGroups = strings.Split(t.BlockGroupList, ",")
+	}
+	return nil
+}
+
+func (ss *SurveyStore) InsertTerritory(t models.Territory) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("insert").
+		Params(t.SurveyID, t.Name, models.FormatPolygon(t.Polygon), strings.Join(t.BlockGroups, ",")).
+		Dest(&id).
+		Fetch()
+	return id, err
+}
+
+func (ss *SurveyStore) UpdateTerritory(t models.Territory) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("update").
+		Params(t.ID, t.SurveyID, t.Name, models.FormatPolygon(t.Polygon), strings.Join(t.BlockGroups, ",")).
+		Dest(&id).
+		Fetch()
+}
+
+// DeleteTerritory removes a territory.  Its members become unrestricted unless they belong to another territory.
+func (ss *SurveyStore) DeleteTerritory(surveyId uuid.UUID, territoryId uuid.UUID) error {
+	var id uuid.UUID
+	return ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("delete").
+		Params(territoryId, surveyId).
+		Dest(&id).
+		Fetch()
+}
+
+// SetTerritoryMembers replaces the members bound to a territory
+func (ss *SurveyStore) SetTerritoryMembers(territoryId uuid.UUID, userIds []string) error {
+	return ss.DS.Transaction(func(tx goquery.Tx) {
+		pgtx := tx.PgxTx()
+		_, err := pgtx.Exec(context.Background(), territoryTable.Statements["deleteMembers"], territoryId)
+		if err != nil {
+			panic(err)
+		}
+		for _, userId := range userIds {
+			_, err = pgtx.Exec(context.Background(), territoryTable.Statements["insertMember"], territoryId, userId)
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+// GetTerritoryCoverage reports the progress of the regular elements within each territory, along with
+// the elements that fall outside every territory
+func (ss *SurveyStore) GetTerritoryCoverage(surveyId uuid.UUID) ([]models.TerritoryCoverage, models.TerritoryCoverage, error) {
+	coverage := []models.TerritoryCoverage{}
+	outside := models.TerritoryCoverage{}
+	err := ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("coverage").
+		Params(surveyId).
+		Dest(&coverage).
+		Fetch()
+	if err != nil {
+		return nil, outside, err
+	}
+	err = ss.DS.Select().
+		DataSet(&territoryTable).
+		StatementKey("outsideCoverage").
+		Params(surveyId).
+		Dest(&outside).
+		Fetch()
+	return coverage, outside, err
+}
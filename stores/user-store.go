@@ -0,0 +1,151 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HydrologicEngineeringCenter/nsi_survey_server/models"
+	"github.com/usace/goquery"
+)
+
+var ErrUserInactive = errors.New("User is deactivated")
+
+// profileTTL is how long an authenticated user's profile is trusted before it is written again
+const profileTTL = 5 * time.Minute
+
+type cachedProfile struct {
+	name    string
+	email   string
+	roles   string
+	expires time.Time
+}
+
+var profiles = struct {
+	sync.Mutex
+	users map[string]cachedProfile
+}{users: map[string]cachedProfile{}}
+
+// invalidateProfiles clears the profile cache so the login time work of every user runs on their next request
+func invalidateProfiles() {
+	profiles.Lock()
+	profiles.users = map[string]cachedProfile{}
+	profiles.Unlock()
+}
+
+// RefreshUser upserts the profile of an authenticated user from their token claims.  Profiles are cached so
+// the database is only written when the name, email, or roles in the token change or the cached profile
+// expires.  Returns true when the profile was written, meaning the login time work of accepting invitations
+// and syncing group memberships should run.
+func (ss *SurveyStore) RefreshUser(user models.UserProfile, roles []string) (bool, error) {
+	now := time.Now()
+	entry := cachedProfile{
+		name:    user.Username,
+		email:   user.Email,
+		roles:   strings.Join(roles, "\n"),
+		expires: now.Add(profileTTL),
+	}
+	profiles.Lock()
+	cached, ok := profiles.users[user.UserID]
+	profiles.Unlock()
+	if ok && now.Before(cached.expires) && cached.name == entry.name && cached.email == entry.email && cached.roles == entry.roles {
+		return false, nil
+	}
+	err := ss.DS.Exec(goquery.NoTx, usersTable.Statements["upsert"], user.UserID, user.Username, user.Email)
+	if err != nil {
+		return false, err
+	}
+	profiles.Lock()
+	profiles.users[user.UserID] = entry
+	profiles.Unlock()
+	return true, nil
+}
+
+// GetUsers pages through the user directory.  Q matches the id, name, or email and active is true, false, or
+// empty for every user.
+func (ss *SurveyStore) GetUsers(q string, active string, rows int, page int) ([]models.UserProfile, error) {
+	users := []models.UserProfile{}
+	err := ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("select").
+		Params(q, active, rows, rows*page).
+		Dest(&users).
+		Fetch()
+	return users, err
+}
+
+// GetUser returns a user's profile along with their survey memberships
+func (ss *SurveyStore) GetUser(userId string) (models.UserDetail, error) {
+	user := models.UserDetail{Memberships: []models.UserMembership{}}
+	err := ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("selectById").
+		Params(userId).
+		Dest(&user.UserProfile).
+		Fetch()
+	if err != nil {
+		return user, err
+	}
+	err = ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("memberships").
+		Params(userId).
+		Dest(&user.Memberships).
+		Fetch()
+	return user, err
+}
+
+// IsActiveUser reports whether a user may be issued new assignments
+func (ss *SurveyStore) IsActiveUser(userId string) (bool, error) {
+	var active bool
+	err := ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("active").
+		Params(userId).
+		Dest(&active).
+		Fetch()
+	return active, err
+}
+
+// SetUserActive activates or deactivates a user.  Returns NoResults if the user does not exist.
+func (ss *SurveyStore) SetUserActive(userId string, active bool) error {
+	var id string
+	return ss.DS.Select().
+		DataSet(&usersTable).
+		StatementKey("setActive").
+		Params(userId, active).
+		Dest(&id).
+		Fetch()
+}
+
+// MergeUsers moves every record of user from to user into, keeping the more privileged role where both are
+// members of a survey, and deletes user from.  Where both users hold an element the completed assignment is
+// kept, else into's, and the attachments of the dropped assignment are returned so their content can be
+// deleted.
+func (ss *SurveyStore) MergeUsers(from string, into string) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	err := ss.DS.Transaction(func(tx goquery.Tx) {
+		err := ss.DS.Select(mergedAttachments).
+			Tx(&tx).
+			Params(from, into).
+			Dest(&attachments).
+			Fetch()
+		if err != nil {
+			panic(err)
+		}
+		pgtx := tx.PgxTx()
+		for _, stmt := range mergeUserStatements {
+			if _, err := pgtx.Exec(context.Background(), stmt, from, into); err != nil {
+				panic(err)
+			}
+		}
+	})
+	if err == nil {
+		invalidateProfiles()
+	}
+	invalidateUserRoles(from)
+	invalidateUserRoles(into)
+	return attachments, err
+}